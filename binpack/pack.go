@@ -1,5 +1,11 @@
 package binpack
 
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
 type Axis int
 
 const (
@@ -8,12 +14,37 @@ const (
 	AxisZ
 )
 
+// Item is a box that may be placed any number of times. Score is the value
+// of one placement, e.g. the number of parts in a pre-packed cluster or a
+// price, and the packer maximizes the total.
 type Item struct {
 	ID    int
-	Score int
+	Score float64
 	Size  Vector
 }
 
+// Objective is a secondary goal used to choose between packings whose
+// scores are equal.
+type Objective int
+
+const (
+	ObjectiveNone Objective = iota
+	ObjectiveHeight
+	ObjectiveVolume
+)
+
+func (o Objective) cost(size Vector) float64 {
+	switch o {
+	case ObjectiveHeight:
+		return float64(size.Z)
+	case ObjectiveVolume:
+		return float64(size.X) * float64(size.Y) * float64(size.Z)
+	}
+	return 0
+}
+
+const scoreEpsilon = 1e-9
+
 type Box struct {
 	Origin Vector
 	Size   Vector
@@ -54,51 +85,115 @@ type Placement struct {
 	Position Vector
 }
 
+// Result is a node in a tree of packing results. Each node places at most
+// one item at its origin and refers to the results packed into the three
+// boxes left over by the cut, so cached results share their sub-results
+// instead of copying placements.
 type Result struct {
-	Score      int
-	Placements []Placement
+	Score    float64
+	Size     Vector
+	Item     *Item
+	Children [3]*Result
+	Offsets  [3]Vector
+}
+
+var emptyResult = &Result{}
+
+func MakeResult(children [3]*Result, offsets [3]Vector, item *Item) *Result {
+	score := item.Score
+	size := item.Size
+	for i, child := range children {
+		if child == nil || child == emptyResult {
+			children[i] = nil
+			continue
+		}
+		score += child.Score
+		size = size.Max(offsets[i].Add(child.Size))
+	}
+	return &Result{score, size, item, children, offsets}
 }
 
-func MakeResult(r0, r1, r2 Result, item Item, position Vector) Result {
-	r3 := Result{item.Score, []Placement{{item, position}}}
-	score := r0.Score + r1.Score + r2.Score + r3.Score
-	n := len(r0.Placements) + len(r1.Placements) + len(r2.Placements) + len(r3.Placements)
-	placements := make([]Placement, 0, n)
-	placements = append(placements, r0.Placements...)
-	placements = append(placements, r1.Placements...)
-	placements = append(placements, r2.Placements...)
-	placements = append(placements, r3.Placements...)
-	return Result{score, placements}
+func (result *Result) Placements() []Placement {
+	return result.appendPlacements(nil, Vector{})
 }
 
-func (result Result) Translate(offset Vector) Result {
-	placements := make([]Placement, len(result.Placements))
-	for i, p := range result.Placements {
-		p.Position = p.Position.Add(offset)
-		placements[i] = p
+func (result *Result) appendPlacements(placements []Placement, offset Vector) []Placement {
+	for i, child := range result.Children {
+		if child != nil {
+			placements = child.appendPlacements(placements, offset.Add(result.Offsets[i]))
+		}
 	}
-	return Result{result.Score, placements}
+	if result.Item != nil {
+		placements = append(placements, Placement{*result.Item, offset})
+	}
+	return placements
+}
+
+// parallelDepth is how many levels of the recursion may evaluate their
+// candidate cuts concurrently.
+const parallelDepth = 3
+
+type Packer struct {
+	Items     []Item
+	Hash      *SpatialHash
+	Workers   int
+	Secondary Objective
+	workers   chan struct{}
+	done      <-chan struct{}
+}
+
+func NewPacker(items []Item) *Packer {
+	hash := NewSpatialHash(1000, DefaultCapacity)
+	return &Packer{Items: items, Hash: hash, Workers: runtime.NumCPU()}
+}
+
+func Pack(items []Item, box Box) *Result {
+	return NewPacker(items).Pack(box)
+}
+
+// Pack returns the best packing of the items into the box. Placement
+// positions are relative to the box origin.
+func (p *Packer) Pack(box Box) *Result {
+	result, _ := p.PackContext(context.Background(), box)
+	return result
 }
 
-func Pack(items []Item, box Box) Result {
-	hash := NewSpatialHash(1000)
-	minVolume := items[0].Size.Sort()
-	for _, item := range items {
+// PackContext is like Pack but stops searching when ctx is done, returning
+// the best packing found so far. The boolean reports whether the search
+// finished, in which case the result is optimal among guillotine packings.
+func (p *Packer) PackContext(ctx context.Context, box Box) (*Result, bool) {
+	minVolume := p.Items[0].Size.Sort()
+	for _, item := range p.Items {
 		minVolume = minVolume.Min(item.Size.Sort())
 	}
-	return pack(items, box, hash, minVolume)
+	workers := p.Workers - 1
+	if workers < 0 {
+		workers = 0
+	}
+	p.workers = make(chan struct{}, workers)
+	p.done = ctx.Done()
+	return p.pack(box, minVolume, 0)
+}
+
+type candidate struct {
+	Item     *Item
+	Boxes    [3]Box
+	Results  [3]*Result
+	Score    float64
+	Complete bool
 }
 
-func pack(items []Item, box Box, hash *SpatialHash, minVolume Vector) Result {
+func (p *Packer) pack(box Box, minVolume Vector, depth int) (*Result, bool) {
 	bs := box.Size
 	if !bs.Sort().Fits(minVolume) {
-		return Result{}
+		return emptyResult, true
 	}
-	if result, ok := hash.Get(bs); ok {
-		return result
+	if result, ok := p.Hash.Get(bs); ok {
+		return result, true
 	}
-	best := Result{}
-	for _, item := range items {
+	var candidates []candidate
+	for k := range p.Items {
+		item := &p.Items[k]
 		s := item.Size
 		if s.X > bs.X || s.Y > bs.Y || s.Z > bs.Z {
 			continue
@@ -111,25 +206,86 @@ func pack(items []Item, box Box, hash *SpatialHash, minVolume Vector) Result {
 		b[4][0], b[4][1], b[4][2] = box.Cuts(AxisZ, AxisX, AxisY, s.Z, s.X, s.Y)
 		b[5][0], b[5][1], b[5][2] = box.Cuts(AxisZ, AxisY, AxisX, s.Z, s.Y, s.X)
 		for i := 0; i < 6; i++ {
-			var r [3]Result
-			score := item.Score
-			for j := 0; j < 3; j++ {
-				r[j] = pack(items, b[i][j], hash, minVolume)
-				score += r[j].Score
-			}
-			if score > best.Score {
-				for j := 0; j < 3; j++ {
-					r[j] = r[j].Translate(b[i][j].Origin)
-				}
-				best = MakeResult(r[0], r[1], r[2], item, box.Origin)
-			}
+			candidates = append(candidates, candidate{Item: item, Boxes: b[i]})
+		}
+	}
+	var wg sync.WaitGroup
+	for i := range candidates {
+		c := &candidates[i]
+		if depth < parallelDepth && p.acquire() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer p.release()
+				p.evaluate(c, minVolume, depth)
+			}()
+		} else {
+			p.evaluate(c, minVolume, depth)
+		}
+	}
+	wg.Wait()
+	best := emptyResult
+	complete := true
+	for _, c := range candidates {
+		complete = complete && c.Complete
+		if c.Results[0] == nil || c.Score < best.Score-scoreEpsilon {
+			continue
+		}
+		if c.Score <= best.Score+scoreEpsilon && p.Secondary == ObjectiveNone {
+			continue
+		}
+		var offsets [3]Vector
+		for j, b := range c.Boxes {
+			offsets[j] = b.Origin.Sub(box.Origin)
+		}
+		result := MakeResult(c.Results, offsets, c.Item)
+		if p.better(result, best) {
+			best = result
 		}
 	}
-	best = best.Translate(box.Origin.Negate())
-	var size Vector
-	for _, p := range best.Placements {
-		size = size.Max(p.Position.Add(p.Item.Size))
+	if complete {
+		p.Hash.Add(best.Size, bs, best)
 	}
-	hash.Add(size, bs, best)
-	return best
+	return best, complete
+}
+
+func (p *Packer) better(a, b *Result) bool {
+	if a.Score > b.Score+scoreEpsilon {
+		return true
+	}
+	if a.Score < b.Score-scoreEpsilon {
+		return false
+	}
+	return p.Secondary.cost(a.Size) < p.Secondary.cost(b.Size)
+}
+
+func (p *Packer) evaluate(c *candidate, minVolume Vector, depth int) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	c.Score = c.Item.Score
+	c.Complete = true
+	for j, b := range c.Boxes {
+		var complete bool
+		c.Results[j], complete = p.pack(b, minVolume, depth+1)
+		c.Score += c.Results[j].Score
+		c.Complete = c.Complete && complete
+	}
+}
+
+// acquire reserves a worker without blocking, reporting false if all
+// workers are busy, in which case the caller does the work itself.
+func (p *Packer) acquire() bool {
+	select {
+	case p.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Packer) release() {
+	<-p.workers
 }
@@ -0,0 +1,151 @@
+package binpack
+
+import (
+	"context"
+	"testing"
+)
+
+func testItems() []Item {
+	return []Item{
+		{0, 1, Vector{4, 3, 2}},
+		{1, 2, Vector{5, 5, 3}},
+		{2, 0.5, Vector{2, 2, 2}},
+		{3, 3, Vector{7, 4, 3}},
+	}
+}
+
+var testBox = Box{Vector{}, Vector{9, 8, 5}}
+
+func packWith(workers, capacity int) (*Packer, *Result) {
+	packer := NewPacker(testItems())
+	packer.Workers = workers
+	packer.Hash = NewSpatialHash(4, capacity)
+	return packer, packer.Pack(testBox)
+}
+
+func TestWorkersAgree(t *testing.T) {
+	_, serial := packWith(1, 0)
+	for _, workers := range []int{2, 4, 8} {
+		_, parallel := packWith(workers, 0)
+		if parallel.Score != serial.Score {
+			t.Errorf("%d workers: score %g, 1 worker: %g", workers, parallel.Score, serial.Score)
+		}
+	}
+}
+
+func TestTinyCache(t *testing.T) {
+	_, unbounded := packWith(1, 0)
+	for _, workers := range []int{1, 4} {
+		packer, tiny := packWith(workers, 3)
+		if tiny.Score != unbounded.Score {
+			t.Errorf("%d workers, capacity 3: score %g, unbounded: %g", workers, tiny.Score, unbounded.Score)
+		}
+		stats := packer.Hash.Stats()
+		if stats.Entries > 3 {
+			t.Errorf("%d workers: %d entries in a cache of 3", workers, stats.Entries)
+		}
+		if stats.Evictions == 0 {
+			t.Errorf("%d workers: nothing was evicted", workers)
+		}
+	}
+}
+
+func TestScoresAndObjective(t *testing.T) {
+	box := Box{Vector{}, Vector{4, 4, 3}}
+	tests := []struct {
+		name      string
+		items     []Item
+		secondary Objective
+		score     float64
+		height    int
+	}{
+		{"small items worth more", []Item{{0, 1, Vector{4, 4, 3}}, {1, 0.5, Vector{2, 2, 3}}}, ObjectiveNone, 2, 3},
+		{"large item worth more", []Item{{0, 3, Vector{4, 4, 3}}, {1, 0.5, Vector{2, 2, 3}}}, ObjectiveNone, 3, 3},
+		{"lowest of equal scores", []Item{{0, 1, Vector{4, 4, 3}}, {1, 1, Vector{4, 4, 2}}}, ObjectiveHeight, 1, 2},
+		{"smallest of equal scores", []Item{{0, 1, Vector{4, 4, 3}}, {1, 1, Vector{4, 4, 2}}}, ObjectiveVolume, 1, 2},
+	}
+	for _, test := range tests {
+		packer := NewPacker(test.items)
+		packer.Secondary = test.secondary
+		result := packer.Pack(box)
+		if result.Score < test.score-scoreEpsilon || result.Score > test.score+scoreEpsilon {
+			t.Errorf("%s: score %g, want %g", test.name, result.Score, test.score)
+		}
+		if result.Size.Z != test.height {
+			t.Errorf("%s: height %d, want %d", test.name, result.Size.Z, test.height)
+		}
+	}
+}
+
+func TestPlacementsValid(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		_, result := packWith(workers, 16)
+		placements := result.Placements()
+		if len(placements) == 0 {
+			t.Fatal("nothing was placed")
+		}
+		var score float64
+		for i, p := range placements {
+			score += p.Item.Score
+			min, max := p.Position, p.Position.Add(p.Item.Size)
+			if !min.GreaterThanOrEqual(testBox.Origin) || !max.LessThanOrEqual(testBox.Origin.Add(testBox.Size)) {
+				t.Errorf("placement %d from %v to %v is outside the box", i, min, max)
+			}
+			for j := 0; j < i; j++ {
+				q := placements[j]
+				qmin, qmax := q.Position, q.Position.Add(q.Item.Size)
+				if min.X < qmax.X && qmin.X < max.X && min.Y < qmax.Y && qmin.Y < max.Y && min.Z < qmax.Z && qmin.Z < max.Z {
+					t.Errorf("placements %d and %d overlap", j, i)
+				}
+			}
+		}
+		if score < result.Score-scoreEpsilon || score > result.Score+scoreEpsilon {
+			t.Errorf("placements score %g, result %g", score, result.Score)
+		}
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	h := NewSpatialHash(10, 2)
+	a, b, c := &Result{Score: 1}, &Result{Score: 2}, &Result{Score: 3}
+	h.Add(Vector{1, 1, 1}, Vector{2, 2, 2}, a)
+	h.Add(Vector{3, 3, 3}, Vector{4, 4, 4}, b)
+	if _, ok := h.Get(Vector{1, 1, 1}); !ok {
+		t.Fatal("a is missing")
+	}
+	// b is now the least recently used
+	h.Add(Vector{5, 5, 5}, Vector{6, 6, 6}, c)
+	if _, ok := h.Get(Vector{3, 3, 3}); ok {
+		t.Error("b was not evicted")
+	}
+	if r, ok := h.Get(Vector{2, 2, 2}); !ok || r != a {
+		t.Error("a was evicted")
+	}
+	if r, ok := h.Get(Vector{5, 6, 5}); !ok || r != c {
+		t.Error("c is missing")
+	}
+	stats := h.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("%d entries and %d evictions, want 2 and 1", stats.Entries, stats.Evictions)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{1, 4} {
+		packer := NewPacker(testItems())
+		packer.Workers = workers
+		result, complete := packer.PackContext(ctx, testBox)
+		if complete {
+			t.Errorf("%d workers: search finished after cancellation", workers)
+		}
+		if result == nil {
+			t.Errorf("%d workers: no result", workers)
+		}
+		// an unfinished search must not be remembered as the best
+		if entries := packer.Hash.Stats().Entries; entries != 0 {
+			t.Errorf("%d workers: %d entries memoized after cancellation", workers, entries)
+		}
+	}
+}
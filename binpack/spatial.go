@@ -1,8 +1,20 @@
 package binpack
 
+import (
+	"container/list"
+	"sync"
+)
+
+const DefaultCapacity = 1000000
+
+// SpatialHash is safe for concurrent use.
 type SpatialHash struct {
 	CellSize int
+	Capacity int
 	Cells    map[SpatialKey][]*SpatialValue
+	stats    HashStats
+	lru      *list.List
+	mu       sync.Mutex
 }
 
 type SpatialKey struct {
@@ -11,12 +23,31 @@ type SpatialKey struct {
 
 type SpatialValue struct {
 	Min, Max Vector
-	Result   Result
+	Result   *Result
+	element  *list.Element
+}
+
+type HashStats struct {
+	Entries   int
+	Hits      int
+	Misses    int
+	Evictions int
+}
+
+func (s HashStats) HitRate() float64 {
+	n := s.Hits + s.Misses
+	if n == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(n)
 }
 
-func NewSpatialHash(cellSize int) *SpatialHash {
+// NewSpatialHash creates a memo table of packing results. At most capacity
+// results are retained; the least recently used result is evicted when the
+// table is full. A capacity <= 0 means the table is unbounded.
+func NewSpatialHash(cellSize, capacity int) *SpatialHash {
 	cells := make(map[SpatialKey][]*SpatialValue)
-	return &SpatialHash{cellSize, cells}
+	return &SpatialHash{CellSize: cellSize, Capacity: capacity, Cells: cells, lru: list.New()}
 }
 
 func (h *SpatialHash) KeyForVector(v Vector) SpatialKey {
@@ -26,8 +57,11 @@ func (h *SpatialHash) KeyForVector(v Vector) SpatialKey {
 	return SpatialKey{x, y, z}
 }
 
-func (h *SpatialHash) Add(min, max Vector, result Result) {
-	value := &SpatialValue{min, max, result}
+func (h *SpatialHash) Add(min, max Vector, result *Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value := &SpatialValue{min, max, result, nil}
+	value.element = h.lru.PushFront(value)
 	k1 := h.KeyForVector(min)
 	k2 := h.KeyForVector(max)
 	for x := k1.X; x <= k2.X; x++ {
@@ -38,14 +72,56 @@ func (h *SpatialHash) Add(min, max Vector, result Result) {
 			}
 		}
 	}
+	for h.Capacity > 0 && h.lru.Len() > h.Capacity {
+		h.evict(h.lru.Back().Value.(*SpatialValue))
+	}
 }
 
-func (h *SpatialHash) Get(v Vector) (Result, bool) {
+func (h *SpatialHash) Get(v Vector) (*Result, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	k := h.KeyForVector(v)
 	for _, value := range h.Cells[k] {
 		if v.GreaterThanOrEqual(value.Min) && v.LessThanOrEqual(value.Max) {
+			h.lru.MoveToFront(value.element)
+			h.stats.Hits++
 			return value.Result, true
 		}
 	}
-	return Result{}, false
+	h.stats.Misses++
+	return nil, false
+}
+
+func (h *SpatialHash) Stats() HashStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := h.stats
+	stats.Entries = h.lru.Len()
+	return stats
+}
+
+func (h *SpatialHash) evict(value *SpatialValue) {
+	h.lru.Remove(value.element)
+	k1 := h.KeyForVector(value.Min)
+	k2 := h.KeyForVector(value.Max)
+	for x := k1.X; x <= k2.X; x++ {
+		for y := k1.Y; y <= k2.Y; y++ {
+			for z := k1.Z; z <= k2.Z; z++ {
+				k := SpatialKey{x, y, z}
+				values := h.Cells[k]
+				for i, v := range values {
+					if v == value {
+						values = append(values[:i], values[i+1:]...)
+						break
+					}
+				}
+				if len(values) == 0 {
+					delete(h.Cells, k)
+				} else {
+					h.Cells[k] = values
+				}
+			}
+		}
+	}
+	h.stats.Evictions++
 }
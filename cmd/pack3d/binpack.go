@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/binpack"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+const (
+	binpackScale   = 100 // binpack units per mm
+	binpackPadding = 2.5 // padding around each item in mm
+
+	// compaction starts cold so the box layout is refined, not scrambled
+	compactMaxTemp = 1e-2
+	compactMinTemp = 5e-5
+)
+
+var binpackRotations []fauxgl.Matrix
+
+func init() {
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			m := fauxgl.Rotate(fauxgl.Vector{0, 0, 1}, float64(i)*math.Pi/2)
+			switch j {
+			case 1:
+				m = m.Rotate(fauxgl.Vector{1, 0, 0}, math.Pi/2)
+			case 2:
+				m = m.Rotate(fauxgl.Vector{0, 1, 0}, math.Pi/2)
+			}
+			binpackRotations = append(binpackRotations, m)
+		}
+	}
+}
+
+var binpackCommand = &command{
+	Name:    "binpack",
+	Args:    "N1 mesh1.stl N2 mesh2.stl ...",
+	Summary: "pack as many items into the build volume as possible",
+	Run:     runBinpack,
+}
+
+var objectives = map[string]binpack.Objective{
+	"none":   binpack.ObjectiveNone,
+	"height": binpack.ObjectiveHeight,
+	"volume": binpack.ObjectiveVolume,
+}
+
+func rotationIndex(m fauxgl.Matrix) int {
+	v := fauxgl.Vector{1, 2, 3}
+	for i, r := range pack3d.Rotations {
+		if r.MulDirection(v).Sub(m.MulDirection(v)).Length() < 1e-6 {
+			return i
+		}
+	}
+	panic("rotation is not axis aligned")
+}
+
+func placementPosition(placement binpack.Placement) fauxgl.Vector {
+	const S = binpackScale
+	const P = binpackPadding
+	p := placement.Position
+	return fauxgl.Vector{float64(p.X)/S + P, float64(p.Y)/S + P, float64(p.Z)/S + P}
+}
+
+// buildModel converts box placements into a pack3d model of the real meshes
+// constrained to the build volume.
+func buildModel(meshes []*fauxgl.Mesh, placements []binpack.Placement, volume fauxgl.Vector, detail int) *pack3d.Model {
+	rotations := make([][]int, len(meshes))
+	translations := make([][]fauxgl.Vector, len(meshes))
+	for _, placement := range placements {
+		v := placementPosition(placement)
+		i := placement.Item.ID / len(binpackRotations)
+		j := placement.Item.ID % len(binpackRotations)
+		size := meshes[i].BoundingBox().Transform(binpackRotations[j]).Size()
+		rotations[i] = append(rotations[i], rotationIndex(binpackRotations[j]))
+		translations[i] = append(translations[i], v.Add(size.MulScalar(0.5)))
+	}
+	model := pack3d.NewModel()
+	model.Container = fauxgl.Box{fauxgl.Vector{}, volume}
+	for i, mesh := range meshes {
+		if len(rotations[i]) > 0 {
+			model.AddAt(mesh, detail, rotations[i], translations[i])
+		}
+	}
+	model.Deviation = math.Cbrt(model.MaxVolume) / 32
+	return model
+}
+
+// placed is one output item: input mesh Index transformed by Matrix.
+type placed struct {
+	Index  int
+	Matrix fauxgl.Matrix
+}
+
+func placementMatrix(mesh *fauxgl.Mesh, placement binpack.Placement) fauxgl.Matrix {
+	v := placementPosition(placement)
+	m := binpackRotations[placement.Item.ID%len(binpackRotations)]
+	min := mesh.BoundingBox().Transform(m).Min
+	return m.Translate(v.Sub(min))
+}
+
+func runBinpack(fs *flag.FlagSet, args []string) error {
+	const S = binpackScale
+	const P = binpackPadding
+
+	timeout := fs.Duration("timeout", 0, "stop searching after this long and use the best packing found")
+	objective := fs.String("objective", "none", "tie-breaker among equal scores: none, height or volume")
+	compact := fs.Int("compact", 0, "anneal the real meshes for this many iterations after box packing")
+	detail := fs.Int("detail", 8, "bvh tree depth used by -compact")
+	volumeFlag := fs.String("volume", "165x165x320", "build volume in mm")
+	workers := fs.Int("workers", 0, "number of concurrent workers (default all cpus)")
+	cache := fs.Int("cache", binpack.DefaultCapacity, "maximum number of cached sub-results, 0 for no limit")
+	prefix := fs.String("o", "binpack", "output file prefix")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	secondary, ok := objectives[*objective]
+	if !ok {
+		return fmt.Errorf("unknown objective: %s", *objective)
+	}
+	volume, err := parseSize(*volumeFlag)
+	if err != nil {
+		return err
+	}
+
+	var items []binpack.Item
+	var meshes []*fauxgl.Mesh
+	var files []string
+	var scores []float64
+
+	var done func()
+
+	meshArgs := parseMeshArgs(fs.Args(), 1)
+	if len(meshArgs) == 0 {
+		return errUsage
+	}
+	for _, arg := range meshArgs {
+		done = timed("loading mesh")
+		mesh, err := fauxgl.LoadMesh(arg.Path)
+		if err != nil {
+			return err
+		}
+		done()
+		warnMeshHealth(arg.Path, mesh)
+
+		i := len(meshes)
+		meshes = append(meshes, mesh)
+		files = append(files, arg.Path)
+		scores = append(scores, arg.Number)
+		box := mesh.BoundingBox()
+		for j, m := range binpackRotations {
+			id := i*len(binpackRotations) + j
+			s := box.Transform(m).Size()
+			sx := int(math.Ceil((s.X + P*2) * S))
+			sy := int(math.Ceil((s.Y + P*2) * S))
+			sz := int(math.Ceil((s.Z + P*2) * S))
+			items = append(items, binpack.Item{id, arg.Number, binpack.Vector{sx, sy, sz}})
+		}
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	done = timed("bin packing")
+	size := binpack.Vector{int(volume.X * S), int(volume.Y * S), int(volume.Z * S)}
+	box := binpack.Box{binpack.Vector{}, size}
+	packer := binpack.NewPacker(items)
+	packer.Secondary = secondary
+	packer.Hash = binpack.NewSpatialHash(1000, *cache)
+	if *workers > 0 {
+		packer.Workers = *workers
+	}
+	result, optimal := packer.PackContext(ctx, box)
+	done()
+
+	stats := packer.Hash.Stats()
+	fmt.Printf("cache: %d entries, %d hits, %d misses (%.1f%%), %d evictions\n",
+		stats.Entries, stats.Hits, stats.Misses, stats.HitRate()*100, stats.Evictions)
+	placements := result.Placements()
+	if optimal {
+		fmt.Printf("packed %d items, score %g\n", len(placements), result.Score)
+	} else {
+		fmt.Printf("packed %d items, score %g (timed out, may not be optimal)\n", len(placements), result.Score)
+	}
+
+	var output []placed
+	if *compact > 0 {
+		done = timed("building bvh trees")
+		model := buildModel(meshes, placements, volume, *detail)
+		done()
+
+		before := model.BoundingBox().Size()
+		model = pack3d.Anneal(model, compactMaxTemp, compactMinTemp, *compact, nil).(*pack3d.Model)
+		after := model.BoundingBox().Size()
+		fmt.Printf("compacted %g x %g x %g to %g x %g x %g\n",
+			before.X, before.Y, before.Z, after.X, after.Y, after.Z)
+
+		lookup := make(map[*fauxgl.Mesh]int)
+		for i, mesh := range meshes {
+			lookup[mesh] = i
+		}
+		for _, item := range model.Items {
+			output = append(output, placed{lookup[item.Mesh], item.Matrix()})
+		}
+	} else {
+		for _, placement := range placements {
+			i := placement.Item.ID / len(binpackRotations)
+			output = append(output, placed{i, placementMatrix(meshes[i], placement)})
+		}
+	}
+
+	done = timed("building result")
+	mesh := fauxgl.NewEmptyMesh()
+	for _, p := range output {
+		m := meshes[p.Index].Copy()
+		m.Transform(p.Matrix)
+		mesh.Add(m)
+	}
+	shift := mesh.MoveTo(fauxgl.Vector{}, fauxgl.Vector{})
+	counts := make([]int, len(meshes))
+	transforms := make([][]fauxgl.Matrix, len(meshes))
+	manifest := pack3d.Manifest{}
+	for _, p := range output {
+		m := shift.Mul(p.Matrix)
+		counts[p.Index]++
+		transforms[p.Index] = append(transforms[p.Index], m)
+		file := pack3d.ManifestFile(files[p.Index], "", filepath.Dir(*prefix))
+		manifest.Items = append(manifest.Items, pack3d.NewManifestItem(file, m, scores[p.Index]))
+	}
+	done()
+
+	for i, file := range files {
+		fmt.Printf("  %4d x %s\n", counts[i], file)
+	}
+
+	done = timed("writing stl file")
+	if err := mesh.SaveSTL(*prefix + ".stl"); err != nil {
+		return err
+	}
+	done()
+
+	done = timed("writing 3mf file")
+	if err := pack3d.Save3MF(*prefix+".3mf", meshes, files, transforms); err != nil {
+		return err
+	}
+	done()
+
+	done = timed("writing manifest")
+	if err := manifest.Save(*prefix + ".json"); err != nil {
+		return err
+	}
+	done()
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var bvhCommand = &command{
+	Name:    "bvh",
+	Args:    "mesh1.stl mesh2.stl ...",
+	Summary: "write the leaf boxes of each mesh's bvh tree to mesh.bvh.DETAIL.stl",
+	Run:     runBVH,
+}
+
+func runBVH(fs *flag.FlagSet, args []string) error {
+	detail := fs.Int("detail", 8, "bvh tree depth")
+	padding := fs.Float64("padding", pack3d.DefaultPadding, "padding around each box in mm")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	for _, arg := range fs.Args() {
+		mesh, err := LoadMesh(arg)
+		if err != nil {
+			return err
+		}
+		tree := pack3d.NewTreeForMesh(mesh, *detail, *padding)
+		mesh = NewEmptyMesh()
+		n := int(math.Pow(2, float64(*detail)))
+		for _, box := range tree[len(tree)-n:] {
+			mesh.Add(NewCubeForBox(box))
+		}
+		ext := path.Ext(arg)
+		if err := mesh.SaveSTL(fmt.Sprintf(arg[:len(arg)-len(ext)]+".bvh.%d.stl", *detail)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var infoCommand = &command{
+	Name:    "info",
+	Args:    "mesh1.stl mesh2.stl ...",
+	Summary: "print size, volume and health information about meshes",
+	Run:     runInfo,
+}
+
+type meshInfo struct {
+	Path         string            `json:"path"`
+	Error        string            `json:"error,omitempty"`
+	Triangles    int               `json:"triangles"`
+	Min          [3]float64        `json:"min"`
+	Max          [3]float64        `json:"max"`
+	Center       [3]float64        `json:"center"`
+	Size         [3]float64        `json:"size"`
+	Volume       float64           `json:"volume"`
+	AABBVolume   float64           `json:"aabb_volume"`
+	SurfaceArea  float64           `json:"surface_area"`
+	Fill         float64           `json:"fill"`          // volume / aabb volume
+	BestRotation int               `json:"best_rotation"` // index into pack3d.Rotations
+	BestSize     [3]float64        `json:"best_size"`     // size in the best rotation
+	Health       pack3d.MeshHealth `json:"health"`
+	Problems     []string          `json:"problems,omitempty"`
+}
+
+func array(v Vector) [3]float64 {
+	return [3]float64{v.X, v.Y, v.Z}
+}
+
+func newMeshInfo(path string, mesh *Mesh) meshInfo {
+	box := mesh.BoundingBox()
+	size := box.Size()
+	info := meshInfo{
+		Path:        path,
+		Triangles:   len(mesh.Triangles),
+		Min:         array(box.Min),
+		Max:         array(box.Max),
+		Center:      array(box.Anchor(V(0.5, 0.5, 0.5))),
+		Size:        array(size),
+		Volume:      mesh.Volume(),
+		AABBVolume:  size.X * size.Y * size.Z,
+		SurfaceArea: mesh.SurfaceArea(),
+	}
+	if info.AABBVolume > 0 {
+		info.Fill = info.Volume / info.AABBVolume
+	}
+	info.BestRotation, info.BestSize = bestRotation(box)
+	info.Health = pack3d.CheckMesh(mesh)
+	info.Problems = info.Health.Problems()
+	return info
+}
+
+// bestRotation returns the rotation that lays the box flattest, preferring
+// the narrowest footprint among equally low ones. Every rotation is axis
+// aligned, so all of them have the same bounding box volume.
+func bestRotation(box Box) (int, [3]float64) {
+	best := -1
+	var bestSize Vector
+	for i, m := range pack3d.Rotations {
+		size := box.Transform(m).Size()
+		if best < 0 || size.Z < bestSize.Z-1e-9 ||
+			(size.Z < bestSize.Z+1e-9 && size.X < bestSize.X-1e-9) {
+			best = i
+			bestSize = size
+		}
+	}
+	return best, array(bestSize)
+}
+
+func runInfo(fs *flag.FlagSet, args []string) error {
+	asJSON := fs.Bool("json", false, "print a JSON array")
+	asCSV := fs.Bool("csv", false, "print CSV with a header row")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || (*asJSON && *asCSV) {
+		return errUsage
+	}
+	var failed error
+	var infos []meshInfo
+	for _, path := range fs.Args() {
+		mesh, err := LoadMesh(path)
+		if err != nil {
+			infos = append(infos, meshInfo{Path: path, Error: err.Error()})
+			failed = fmt.Errorf("could not load all meshes")
+			continue
+		}
+		infos = append(infos, newMeshInfo(path, mesh))
+	}
+	switch {
+	case *asJSON:
+		data, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case *asCSV:
+		if err := writeInfoCSV(infos); err != nil {
+			return err
+		}
+	default:
+		for _, info := range infos {
+			printInfo(info)
+		}
+	}
+	return failed
+}
+
+func printInfo(info meshInfo) {
+	fmt.Println(info.Path)
+	if info.Error != "" {
+		fmt.Println(info.Error)
+		return
+	}
+	fmt.Printf("  triangles = %d\n", info.Triangles)
+	fmt.Printf("  x range   = %g to %g\n", info.Min[0], info.Max[0])
+	fmt.Printf("  y range   = %g to %g\n", info.Min[1], info.Max[1])
+	fmt.Printf("  z range   = %g to %g\n", info.Min[2], info.Max[2])
+	fmt.Printf("  center    = %g, %g, %g\n", info.Center[0], info.Center[1], info.Center[2])
+	fmt.Printf("  size      = %g x %g x %g\n", info.Size[0], info.Size[1], info.Size[2])
+	fmt.Printf("  volume    = %g\n", info.Volume)
+	fmt.Printf("  aabb vol  = %g\n", info.AABBVolume)
+	fmt.Printf("  area      = %g\n", info.SurfaceArea)
+	fmt.Printf("  fill      = %.1f%%\n", info.Fill*100)
+	fmt.Printf("  flattest  = %g x %g x %g (rotation %d)\n",
+		info.BestSize[0], info.BestSize[1], info.BestSize[2], info.BestRotation)
+	if len(info.Problems) == 0 {
+		fmt.Printf("  health    = ok\n")
+	}
+	for _, problem := range info.Problems {
+		fmt.Printf("  problem   = %s\n", problem)
+	}
+	fmt.Println()
+}
+
+var infoCSVHeader = []string{
+	"path", "error", "triangles",
+	"min_x", "min_y", "min_z", "max_x", "max_y", "max_z",
+	"center_x", "center_y", "center_z", "size_x", "size_y", "size_z",
+	"volume", "aabb_volume", "surface_area", "fill",
+	"best_rotation", "best_size_x", "best_size_y", "best_size_z",
+	"degenerate", "duplicate", "boundary_edges", "non_manifold_edges", "inconsistent_edges", "signed_volume",
+}
+
+func writeInfoCSV(infos []meshInfo) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(infoCSVHeader)
+	f := func(x float64) string {
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	for _, info := range infos {
+		row := []string{info.Path, info.Error, strconv.Itoa(info.Triangles)}
+		for _, v := range [][3]float64{info.Min, info.Max, info.Center, info.Size} {
+			row = append(row, f(v[0]), f(v[1]), f(v[2]))
+		}
+		row = append(row, f(info.Volume), f(info.AABBVolume), f(info.SurfaceArea), f(info.Fill))
+		row = append(row, strconv.Itoa(info.BestRotation))
+		row = append(row, f(info.BestSize[0]), f(info.BestSize[1]), f(info.BestSize[2]))
+		h := info.Health
+		for _, n := range []int{h.Degenerate, h.Duplicate, h.BoundaryEdges, h.NonManifoldEdges, h.InconsistentEdges} {
+			row = append(row, strconv.Itoa(n))
+		}
+		row = append(row, f(h.SignedVolume))
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
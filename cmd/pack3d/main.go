@@ -1,22 +1,116 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"math"
-	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fogleman/fauxgl"
 	"github.com/fogleman/pack3d/pack3d"
 )
 
-const (
-	bvhDetail           = 8
-	annealingIterations = 2000000
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+	Run     func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = []*command{
+	packCommand,
+	binpackCommand,
+	bvhCommand,
+	infoCommand,
+	renderCommand,
+	separateCommand,
+	sortCommand,
+	serveCommand,
+	validateCommand,
+}
+
+var (
+	// errUsage reports bad arguments; the command's usage is printed
+	errUsage = errors.New("invalid arguments")
+
+	// errFlags reports a flag error already printed by the flag package
+	errFlags = errors.New("invalid flags")
 )
 
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pack3d <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "If no command is given, pack is assumed.")
+	fmt.Fprintln(os.Stderr, "Run 'pack3d <command> -h' for help on a command.")
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	}
+	c := packCommand
+	for _, x := range commands {
+		if x.Name == args[0] {
+			c = x
+			args = args[1:]
+			break
+		}
+	}
+	os.Exit(run(c, args))
+}
+
+func run(c *command, args []string) int {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: pack3d %s [flags] %s\n", c.Name, c.Args)
+		fmt.Fprintf(os.Stderr, "%s\n", c.Summary)
+		var n int
+		fs.VisitAll(func(*flag.Flag) { n++ })
+		if n > 0 {
+			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(os.Stderr, "Flags:")
+			fs.PrintDefaults()
+		}
+	}
+	err := c.Run(fs, args)
+	switch {
+	case err == nil:
+		return 0
+	case err == flag.ErrHelp:
+		return 0
+	case err == errUsage:
+		fs.Usage()
+		return 2
+	case err == errFlags:
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "pack3d %s: %v\n", c.Name, err)
+	return 1
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && err != flag.ErrHelp {
+		return errFlags
+	}
+	return err
+}
+
 func timed(name string) func() {
 	if len(name) > 0 {
 		fmt.Printf("%s... ", name)
@@ -27,66 +121,77 @@ func timed(name string) func() {
 	}
 }
 
-func main() {
-	var done func()
+// warnMeshHealth prints a warning if mesh has problems that make its volume
+// unreliable.
+func warnMeshHealth(path string, mesh *fauxgl.Mesh) {
+	if problems := pack3d.CheckMesh(mesh).Problems(); len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", path, strings.Join(problems, ", "))
+	}
+}
 
-	rand.Seed(time.Now().UTC().UnixNano())
+// meshArg is a mesh path and the number given before it on the command
+// line, as in "pack3d 2 a.stl 3 b.stl c.stl".
+type meshArg struct {
+	Path   string
+	Number float64
+}
 
-	model := pack3d.NewModel()
-	count := 1
-	ok := false
-	var totalVolume float64
-	for _, arg := range os.Args[1:] {
-		_count, err := strconv.ParseInt(arg, 0, 0)
+func parseMeshArgs(args []string, number float64) []meshArg {
+	var result []meshArg
+	for _, arg := range args {
+		n, err := strconv.ParseFloat(arg, 64)
 		if err == nil {
-			count = int(_count)
+			number = n
 			continue
 		}
+		result = append(result, meshArg{arg, number})
+	}
+	return result
+}
 
-		done = timed(fmt.Sprintf("loading mesh %s", arg))
-		mesh, err := fauxgl.LoadMesh(arg)
-		if err != nil {
-			panic(err)
+// parseImageSize parses image sizes like "1600x1200".
+func parseImageSize(s string) (int, int, error) {
+	fields := strings.Split(s, "x")
+	if len(fields) == 2 {
+		w, err1 := strconv.Atoi(fields[0])
+		h, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil && w > 0 && h > 0 {
+			return w, h, nil
 		}
-		done()
-
-		totalVolume += mesh.BoundingBox().Volume()
-		size := mesh.BoundingBox().Size()
-		fmt.Printf("  %d triangles\n", len(mesh.Triangles))
-		fmt.Printf("  %g x %g x %g\n", size.X, size.Y, size.Z)
-
-		done = timed("centering mesh")
-		mesh.Center()
-		done()
-
-		done = timed("building bvh tree")
-		model.Add(mesh, bvhDetail, count)
-		ok = true
-		done()
 	}
+	return 0, 0, fmt.Errorf("invalid image size %q, want WxH", s)
+}
 
-	if !ok {
-		fmt.Println("Usage: pack3d N1 mesh1.stl N2 mesh2.stl ...")
-		fmt.Println(" - Packs N copies of each mesh into as small of a volume as possible.")
-		fmt.Println(" - Runs forever, looking for the best packing.")
-		fmt.Println(" - Results are written to disk whenever a new best is found.")
-		return
+// parseVector parses vectors like "1,1.5,1".
+func parseVector(s string) (fauxgl.Vector, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return fauxgl.Vector{}, fmt.Errorf("invalid vector %q, want X,Y,Z", s)
 	}
+	var v [3]float64
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return fauxgl.Vector{}, fmt.Errorf("invalid vector %q, want X,Y,Z", s)
+		}
+		v[i] = x
+	}
+	return fauxgl.Vector{v[0], v[1], v[2]}, nil
+}
 
-	side := math.Pow(totalVolume, 1.0/3)
-	model.Deviation = side / 32
-
-	best := 1e9
-	for {
-		model = model.Pack(annealingIterations, nil)
-		score := model.Energy()
-		if score < best {
-			best = score
-			done = timed("writing mesh")
-			model.Mesh().SaveSTL(fmt.Sprintf("pack3d-%.3f.stl", score))
-			// model.TreeMesh().SaveSTL(fmt.Sprintf("out%dtree.stl", int(score*100000)))
-			done()
+// parseSize parses dimensions like "165x165x320".
+func parseSize(s string) (fauxgl.Vector, error) {
+	fields := strings.Split(s, "x")
+	if len(fields) != 3 {
+		return fauxgl.Vector{}, fmt.Errorf("invalid size %q, want XxYxZ", s)
+	}
+	var v [3]float64
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil || x <= 0 {
+			return fauxgl.Vector{}, fmt.Errorf("invalid size %q, want XxYxZ", s)
 		}
-		model.Reset()
+		v[i] = x
 	}
+	return fauxgl.Vector{v[0], v[1], v[2]}, nil
 }
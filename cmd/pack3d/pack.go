@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var packCommand = &command{
+	Name:    "pack",
+	Args:    "N1 mesh1.stl N2 mesh2.stl ... | -job job.json",
+	Summary: "tightly pack N copies of each mesh into as small of a volume as possible",
+	Run:     runPack,
+}
+
+func runPack(fs *flag.FlagSet, args []string) error {
+	detail := fs.Int("detail", 8, "bvh tree depth")
+	iterations := fs.Int("iterations", 2000000, "annealing iterations per attempt")
+	output := fs.String("o", "pack3d", "output file prefix")
+	jobPath := fs.String("job", "", "run the packing described by a JSON job file")
+	preview := fs.String("preview", "", "render the best packing so far to this PNG file while annealing")
+	previewInterval := fs.Duration("preview-interval", 10*time.Second, "minimum time between preview images")
+	separable := fs.Bool("separable", false, "only accept packings where every item can be pulled out along an axis")
+	balance := fs.Float64("balance", 0, "weight of keeping the center of mass at the center of the build")
+	slabBalance := fs.Float64("slab-balance", 0, "weight of spreading the mass evenly over the height of the build")
+	slabs := fs.Int("slabs", pack3d.DefaultSlabs, "number of horizontal slabs for -slab-balance")
+	thermalWeight := fs.Float64("thermal-weight", 0, "weight of keeping the fused area of each layer under -thermal-limit")
+	thermalLimit := fs.Float64("thermal-limit", 0.3, "largest fused area of a layer as a fraction of the build plate")
+	layerHeight := fs.Float64("layer-height", pack3d.DefaultLayerHeight, "thickness of the layers for -thermal-weight and the layer report")
+	nesting := fs.String("nesting", "allow", "allow, prefer or forbid placing items inside the voids of other items")
+	placement := fs.String("placement", "spiral", "initial placement: spiral, greedy, layers or binpack")
+	schedule := fs.String("schedule", "exponential", "comma separated cooling schedules used by turns: exponential, linear, log, adaptive or reheat")
+	calibrate := fs.Bool("calibrate", false, "calibrate the starting temperature from sampled moves")
+	report := fs.String("report", "", "comma separated report formats written with each result: txt, json or html (default none)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	reports, err := parseReportFormats(*report)
+	if err != nil {
+		return err
+	}
+
+	var done func()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	var previews *previewer
+	if *preview != "" {
+		previews = newPreviewer(*preview, *previewInterval)
+	}
+
+	if *jobPath != "" {
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+		// the job file describes the whole packing, only previews are
+		// set on the command line
+		var extra []string
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "job", "preview", "preview-interval":
+			default:
+				extra = append(extra, "-"+f.Name)
+			}
+		})
+		if len(extra) != 0 {
+			return fmt.Errorf("%s cannot be combined with -job", strings.Join(extra, ", "))
+		}
+		return runJob(*jobPath, previews)
+	}
+
+	model := pack3d.NewModel()
+	switch *nesting {
+	case "allow":
+	case "prefer":
+		model.Nesting = pack3d.NestingPreferred
+	case "forbid":
+		model.Nesting = pack3d.NestingForbidden
+	default:
+		return fmt.Errorf("unknown nesting mode: %s", *nesting)
+	}
+	switch *placement {
+	case "spiral":
+	case "greedy":
+		model.Placement = pack3d.PlaceGreedy
+	case "layers":
+		model.Placement = pack3d.PlaceLayers
+	case "binpack":
+		model.Placement = pack3d.PlaceBinpack
+	default:
+		return fmt.Errorf("unknown placement: %s", *placement)
+	}
+	var schedules []pack3d.Schedule
+	for _, name := range strings.Split(*schedule, ",") {
+		s, err := pack3d.ParseSchedule(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		schedules = append(schedules, s)
+	}
+	model.Separable = *separable
+	model.BalanceWeight = *balance
+	model.SlabWeight = *slabBalance
+	model.Slabs = *slabs
+	model.ThermalWeight = *thermalWeight
+	model.ThermalLimit = *thermalLimit
+	model.LayerHeight = *layerHeight
+	var totalVolume float64
+	meshArgs := parseMeshArgs(fs.Args(), 1)
+	if len(meshArgs) == 0 {
+		return errUsage
+	}
+	for _, arg := range meshArgs {
+		count := int(arg.Number)
+		if float64(count) != arg.Number || count < 1 {
+			return fmt.Errorf("invalid count %g for %s", arg.Number, arg.Path)
+		}
+
+		done = timed(fmt.Sprintf("loading mesh %s", arg.Path))
+		mesh, err := fauxgl.LoadMesh(arg.Path)
+		if err != nil {
+			return err
+		}
+		done()
+
+		warnMeshHealth(arg.Path, mesh)
+
+		totalVolume += mesh.BoundingBox().Volume()
+		size := mesh.BoundingBox().Size()
+		fmt.Printf("  %d triangles\n", len(mesh.Triangles))
+		fmt.Printf("  %g x %g x %g\n", size.X, size.Y, size.Z)
+
+		done = timed("building bvh tree")
+		if err := model.AddPart(pack3d.Part{Name: arg.Path, Mesh: mesh, Count: count}, *detail); err != nil {
+			return err
+		}
+		done()
+	}
+
+	side := math.Pow(totalVolume, 1.0/3)
+	model.Deviation = side / 32
+	if model.Placement == pack3d.PlaceBinpack {
+		// the box layout needs all of the items
+		if err := model.Reset(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Runs until stopped, writing results whenever a new best is found.")
+	best := 1e9
+	var stats pack3d.RunStats
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		// model is the best of the last attempt, with its temperatures
+		model.Cooling.Schedule = schedules[(attempt-1)%len(schedules)]
+		model.Cooling.Calibrate = *calibrate
+		improved := false
+		step := 0
+		printProgress := pack3d.PrintProgress()
+		progress := func(i, steps int, energy float64) {
+			step = i
+			printProgress(i, steps, energy)
+		}
+		record := func(state pack3d.Annealable) {
+			if energy := state.Energy(); energy < best {
+				best = energy
+				improved = true
+				stats = pack3d.RunStats{Attempt: attempt, Iterations: (attempt-1)*(*iterations) + step, Elapsed: time.Since(start)}
+			}
+			if previews != nil {
+				previews.Callback(state)
+			}
+		}
+		model = model.PackProgress(context.Background(), *iterations, record, progress)
+		fmt.Println()
+		if previews != nil {
+			previews.Flush()
+		}
+		if improved {
+			score := model.Energy()
+			done = timed("writing mesh")
+			if err := model.Mesh().SaveSTL(fmt.Sprintf("%s-%.3f.stl", *output, score)); err != nil {
+				return err
+			}
+			if *thermalWeight > 0 {
+				if err := model.SaveLayers(fmt.Sprintf("%s-%.3f.layers.csv", *output, score)); err != nil {
+					return err
+				}
+			}
+			for _, format := range reports {
+				if err := saveReport(model, stats, fmt.Sprintf("%s-%.3f.%s", *output, score, format)); err != nil {
+					return err
+				}
+			}
+			done()
+		}
+		if err := model.Reset(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseReportFormats parses the -report flag into the extensions of the
+// report files, with json reports named .report.json.
+func parseReportFormats(s string) ([]string, error) {
+	var result []string
+	for _, format := range strings.Split(s, ",") {
+		switch format = strings.TrimSpace(format); format {
+		case "":
+		case "txt", "html":
+			result = append(result, format)
+		case "json":
+			result = append(result, "report.json")
+		default:
+			return nil, fmt.Errorf("unknown report format: %s", format)
+		}
+	}
+	return result, nil
+}
+
+// saveReport writes the report of model to path, rendering the model for
+// HTML reports.
+func saveReport(model *pack3d.Model, stats pack3d.RunStats, path string) error {
+	var render image.Image
+	if strings.HasSuffix(path, ".html") {
+		render = renderModel(model, 1024, 768)
+	}
+	return pack3d.NewReport(model, stats).Save(path, render)
+}
+
+func runJob(path string, previews *previewer) error {
+	job, err := pack3d.LoadJob(path)
+	if err != nil {
+		return err
+	}
+	job.Warn = func(path string, health pack3d.MeshHealth) {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", path, strings.Join(health.Problems(), ", "))
+	}
+	job.Render = func(model *pack3d.Model) image.Image {
+		return renderModel(model, 1024, 768)
+	}
+	if len(job.Outputs) == 0 {
+		return fmt.Errorf("%s: outputs: at least one output is required", path)
+	}
+	if job.Attempts == 0 && job.TimeBudget == 0 {
+		fmt.Println("Runs until stopped, writing results whenever a new best is found.")
+	}
+	var callback func(*pack3d.Model)
+	if previews != nil {
+		callback = previews.Update
+	}
+	best, err := job.Run(context.Background(), callback)
+	if previews != nil {
+		previews.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	report := pack3d.NewReport(best, job.Stats)
+	fmt.Printf("best: %.3f, %.2f x %.2f x %.2f, %.1f%% dense, found in attempt %d after %s by %s\n",
+		report.Energy, report.Size[0], report.Size[1], report.Size[2], report.Density*100,
+		report.Attempt, time.Duration(report.Elapsed).Round(time.Millisecond), report.Schedule)
+	if job.Balance > 0 || job.SlabBalance > 0 {
+		c := best.CenterOfMass()
+		fmt.Printf("center of mass: %.1f, %.1f, %.1f (%.1f%% off center), slab imbalance %.3f\n",
+			c.X, c.Y, c.Z, best.BalanceOffset()*100, best.SlabImbalance())
+	}
+	return nil
+}
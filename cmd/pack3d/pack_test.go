@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPackJobFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-job", "missing.json", "-detail", "6"}, "-detail cannot be combined with -job"},
+		{[]string{"-detail", "6", "-job", "missing.json", "-o", "out"}, "-detail, -o cannot be combined with -job"},
+		{[]string{"-job", "missing.json", "a.stl"}, errUsage.Error()},
+		// previews are not part of the job, so the job file is read
+		{[]string{"-job", "missing.json", "-preview", "p.png", "-preview-interval", "1s"}, "missing.json"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("pack", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		err := runPack(fs, test.args)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error %v, want %q", test.args, err, test.err)
+		}
+	}
+}
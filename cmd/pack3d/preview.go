@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+// previewer renders the best model so far to a PNG, at most once per
+// Interval, so that long runs can be watched. A model that comes too soon
+// is rendered once the interval has passed, or by Flush.
+type previewer struct {
+	Path     string
+	Interval time.Duration
+	Width    int
+	Height   int
+
+	mu      sync.Mutex
+	last    time.Time
+	pending *pack3d.Model
+	timer   *time.Timer
+}
+
+func newPreviewer(path string, interval time.Duration) *previewer {
+	return &previewer{Path: path, Interval: interval, Width: 1024, Height: 768}
+}
+
+// Callback is Update for an annealing callback, whose state must be a
+// *pack3d.Model.
+func (p *previewer) Callback(state pack3d.Annealable) {
+	p.Update(state.(*pack3d.Model))
+}
+
+// Update renders model, or a copy of it later if an image was written less
+// than Interval ago.
+func (p *previewer) Update(model *pack3d.Model) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the caller may go on to change the model
+	p.pending = model.Copy().(*pack3d.Model)
+	if wait := p.Interval - time.Since(p.last); wait > 0 {
+		if p.timer == nil {
+			p.timer = time.AfterFunc(wait, p.Flush)
+		}
+		return
+	}
+	p.flush()
+}
+
+// Flush renders the last model passed to Update if it was not rendered yet.
+func (p *previewer) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flush()
+}
+
+func (p *previewer) flush() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if p.pending == nil {
+		return
+	}
+	model := p.pending
+	p.pending = nil
+	p.last = time.Now()
+	if err := p.save(model); err != nil {
+		fmt.Fprintf(os.Stderr, "preview: %v\n", err)
+	}
+}
+
+// save writes to a temporary file first so that viewers never see a
+// partial image.
+func (p *previewer) save(model *pack3d.Model) error {
+	image := renderModel(model, p.Width, p.Height)
+	temp := filepath.Join(filepath.Dir(p.Path), "."+filepath.Base(p.Path)+".tmp")
+	if err := fauxgl.SavePNG(temp, image); err != nil {
+		return err
+	}
+	return os.Rename(temp, p.Path)
+}
+
+// renderModel draws the items of model in their colors, inside its container
+// if it has one.
+func renderModel(model *pack3d.Model, width, height int) image.Image {
+	s := scene{}
+	s.Background = viewsBackground
+	s.Meshes = model.Meshes()
+	s.Volume = model.Container
+	for i := range s.Meshes {
+		s.Colors = append(s.Colors, itemColor(i))
+	}
+	s.Camera = fitCamera(s.Bounds(), fauxgl.V(1, 1.5, 1), 30, width, height, 2)
+	return s.Render()
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+	"github.com/nfnt/resize"
+)
+
+var renderCommand = &command{
+	Name:    "render",
+	Args:    "result.stl|result.3mf|result.json",
+	Summary: "render a packing result with each item in its own color, or build-up animation frames",
+	Run:     runRender,
+}
+
+type camera struct {
+	Width, Height int // output size in pixels
+	Scale         int // optional supersampling
+	Fovy          float64
+	Near, Far     float64
+	Eye           Vector
+	Center        Vector
+	Up            Vector
+	Light         Vector
+}
+
+func (c camera) Matrix() Matrix {
+	aspect := float64(c.Width) / float64(c.Height)
+	return LookAt(c.Eye, c.Center, c.Up).Perspective(c.Fovy, aspect, c.Near, c.Far)
+}
+
+var viewsCamera = camera{
+	2048, 2048, 4, 35, 1, 1000,
+	V(100, 200, 100), V(0, 0, 0), V(0, 0, 1), V(0.75, 1, 0.25).Normalize(),
+}
+
+var (
+	viewsColor      = HexColor("#468966") // object color
+	viewsBackground = HexColor("#FFF8E3") // background color
+	fixtureColor    = HexColor("#2A2C2B")
+	outlineColor    = HexColor("#2A2C2B")
+)
+
+// fitCamera returns a camera of the given size looking at box from the
+// direction of dir, far enough away to see all of it.
+func fitCamera(box Box, dir Vector, fovy float64, width, height, scale int) camera {
+	center := box.Center()
+	radius := box.Size().Length() / 2
+	half := Radians(fovy / 2)
+	if width < height {
+		half = math.Atan(math.Tan(half) * float64(width) / float64(height))
+	}
+	distance := radius / math.Sin(half) * 1.05
+	eye := center.Add(dir.Normalize().MulScalar(distance))
+	up := V(0, 0, 1)
+	if math.Abs(dir.Normalize().Z) > 0.999 {
+		up = V(0, 1, 0)
+	}
+	light := V(0.25, 0.5, 1).Normalize()
+	return camera{
+		width, height, scale, fovy, distance - radius*1.1, distance + radius*1.1,
+		eye, center, up, light,
+	}
+}
+
+// itemColor returns a distinct color for each index by stepping around the
+// color wheel by the golden angle.
+func itemColor(i int) Color {
+	h := math.Mod(float64(i)*0.618033988749895, 1)
+	return hsvColor(h, 0.55, 0.85)
+}
+
+func hsvColor(h, s, v float64) Color {
+	h = h * 6
+	i := math.Floor(h)
+	f := h - i
+	p := v * (1 - s)
+	q := v * (1 - s*f)
+	t := v * (1 - s*(1-f))
+	switch int(i) % 6 {
+	case 0:
+		return Color{v, t, p, 1}
+	case 1:
+		return Color{q, v, p, 1}
+	case 2:
+		return Color{p, v, t, 1}
+	case 3:
+		return Color{p, q, v, 1}
+	case 4:
+		return Color{t, p, v, 1}
+	}
+	return Color{v, p, q, 1}
+}
+
+// scene is a set of colored meshes seen by a camera, with an optional
+// fixture and build volume outline.
+type scene struct {
+	Camera     camera
+	Background Color
+	Meshes     []*Mesh
+	Colors     []Color
+	Fixture    *Mesh
+	Volume     Box // outlined unless empty
+}
+
+// context returns a rendering context with the background, build volume and
+// fixture drawn, and the shader used for the meshes.
+func (s *scene) context() (*Context, *PhongShader) {
+	c := s.Camera
+	context := NewContext(c.Width*c.Scale, c.Height*c.Scale)
+	context.ClearColorBufferWith(s.Background)
+	matrix := c.Matrix()
+	if s.Volume != EmptyBox {
+		context.Shader = NewSolidColorShader(matrix, outlineColor)
+		context.LineWidth = float64(c.Scale * 2)
+		context.DrawMesh(NewCubeOutlineForBox(s.Volume))
+	}
+	shader := NewPhongShader(matrix, c.Light, c.Eye)
+	context.Shader = shader
+	if s.Fixture != nil {
+		shader.ObjectColor = fixtureColor
+		context.DrawMesh(s.Fixture)
+	}
+	return context, shader
+}
+
+// image downsamples the context for antialiasing.
+func (s *scene) image(context *Context) image.Image {
+	c := s.Camera
+	image := context.Image()
+	if c.Scale > 1 {
+		image = resize.Resize(uint(c.Width), uint(c.Height), image, resize.Bilinear)
+	}
+	return image
+}
+
+// Bounds returns the box containing everything in the scene.
+func (s *scene) Bounds() Box {
+	box := s.Volume
+	if s.Fixture != nil {
+		box = box.Extend(s.Fixture.BoundingBox())
+	}
+	for _, mesh := range s.Meshes {
+		box = box.Extend(mesh.BoundingBox())
+	}
+	return box
+}
+
+func (s *scene) Render() image.Image {
+	context, shader := s.context()
+	for i, mesh := range s.Meshes {
+		shader.ObjectColor = s.Colors[i]
+		context.DrawMesh(mesh)
+	}
+	return s.image(context)
+}
+
+// Animate calls frame with n+1 images that add the meshes one at a time.
+func (s *scene) Animate(frame func(i int, image image.Image) error) error {
+	context, shader := s.context()
+	for i := 0; i <= len(s.Meshes); i++ {
+		if i > 0 {
+			shader.ObjectColor = s.Colors[i-1]
+			context.DrawMesh(s.Meshes[i-1])
+		}
+		if err := frame(i, s.image(context)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runRender(fs *flag.FlagSet, args []string) error {
+	output := fs.String("o", "render.png", "output file, numbered for -animate")
+	count := fs.Int("n", 0, "split an stl result into N equal-size items instead of its connected parts")
+	sizeFlag := fs.String("size", "1600x1200", "image size in pixels")
+	scale := fs.Int("scale", 4, "supersampling factor")
+	dirFlag := fs.String("camera", "1,1.5,1", "direction from the center of the result to the camera")
+	fovy := fs.Float64("fovy", 30, "vertical field of view in degrees")
+	background := fs.String("background", "#FFFFFF", "background color")
+	color := fs.String("color", "", "color for every item instead of one color per item")
+	volumeFlag := fs.String("volume", "", "outline a build volume like 165x165x320 with its corner at the origin")
+	fixture := fs.String("fixture", "", "mesh drawn beneath the items")
+	animate := fs.Bool("animate", false, "write build-up frames adding the items bottom to top, or in the order of a sorted manifest")
+	sheet := fs.Bool("sheet", false, "write a contact sheet of front, side, top and iso views with -size cells")
+	frames := fs.Int("turntable", 0, "write N frames of the camera circling the result")
+	font := fs.String("font", "", "TrueType font for -sheet annotations")
+	views := fs.Bool("views", false, "render six axis-swapped views of each mesh argument to mesh.N.png")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *views {
+		if fs.NArg() == 0 {
+			return errUsage
+		}
+		for _, path := range fs.Args() {
+			if err := renderViews(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if fs.NArg() != 1 || *scale < 1 || *frames < 0 || (*animate && (*sheet || *frames > 0)) {
+		return errUsage
+	}
+
+	width, height, err := parseImageSize(*sizeFlag)
+	if err != nil {
+		return err
+	}
+	dir, err := parseVector(*dirFlag)
+	if err != nil {
+		return err
+	}
+
+	var done func()
+
+	s := scene{}
+	s.Background = HexColor(*background)
+	if *volumeFlag != "" {
+		volume, err := parseSize(*volumeFlag)
+		if err != nil {
+			return err
+		}
+		s.Volume = Box{Vector{}, volume}
+	}
+	if *fixture != "" {
+		done = timed("loading fixture")
+		s.Fixture, err = LoadMesh(*fixture)
+		if err != nil {
+			return err
+		}
+		done()
+	}
+
+	done = timed("loading result")
+	s.Meshes, _, err = loadResult(fs.Arg(0), *count)
+	if err != nil {
+		return err
+	}
+	done()
+	fmt.Printf("  %d items\n", len(s.Meshes))
+
+	if *animate && !sortedManifest(fs.Arg(0)) {
+		sortBottomUp(s.Meshes)
+	}
+	for i := range s.Meshes {
+		if *color != "" {
+			s.Colors = append(s.Colors, HexColor(*color))
+		} else {
+			s.Colors = append(s.Colors, itemColor(i))
+		}
+	}
+	s.Camera = fitCamera(s.Bounds(), dir, *fovy, width, height, *scale)
+
+	ext := filepath.Ext(*output)
+	base := strings.TrimSuffix(*output, ext)
+	saveFrame := func(i int, image image.Image) error {
+		done := timed(fmt.Sprintf("writing frame %d", i))
+		defer done()
+		return SavePNG(fmt.Sprintf("%s.%03d%s", base, i, ext), image)
+	}
+	if *animate {
+		return s.Animate(saveFrame)
+	}
+	if *frames > 0 {
+		if err := turntable(s, dir, *fovy, *frames, saveFrame); err != nil {
+			return err
+		}
+		if !*sheet {
+			return nil
+		}
+	}
+	if *sheet {
+		summary := summarize(filepath.Base(fs.Arg(0)), &s)
+		image, err := renderSheet(s, summary, width, height, *fovy, *font)
+		if err != nil {
+			return err
+		}
+		return SavePNG(*output, image)
+	}
+	done = timed("rendering")
+	image := s.Render()
+	done()
+	return SavePNG(*output, image)
+}
+
+// sortedManifest reports whether path is a manifest already put in build
+// order by the sort command.
+func sortedManifest(path string) bool {
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		return false
+	}
+	manifest, err := pack3d.LoadManifest(path)
+	return err == nil && manifest.Order != ""
+}
+
+// loadResult returns the placed items of a packing result and their names:
+// the meshes of a manifest or 3MF file, or an stl split into n equal-size
+// items or its connected parts if n is 0. Hollow parts keep their cavities,
+// so that an inner wall is not taken for an item inside its own part.
+func loadResult(path string, n int) ([]*Mesh, []string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		manifest, err := pack3d.LoadManifest(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		meshes, err := manifest.Meshes(filepath.Dir(path))
+		if err != nil {
+			return nil, nil, err
+		}
+		names := make([]string, len(manifest.Items))
+		for i, item := range manifest.Items {
+			names[i] = item.File
+		}
+		return meshes, names, nil
+	case ".3mf":
+		return pack3d.Load3MF(path)
+	}
+	mesh, err := LoadMesh(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var groups [][]*Triangle
+	if n == 0 {
+		shells := nestShells(connectedParts(mesh, 0))
+		for _, s := range shells {
+			if s.Cavity() {
+				continue
+			}
+			group := append([]*Triangle(nil), s.Mesh.Triangles...)
+			for _, c := range s.Cavities {
+				group = append(group, shells[c].Mesh.Triangles...)
+			}
+			groups = append(groups, group)
+		}
+	} else {
+		if len(mesh.Triangles)%n != 0 {
+			return nil, nil, fmt.Errorf("%d triangles cannot be split into %d equal parts", len(mesh.Triangles), n)
+		}
+		t := len(mesh.Triangles) / n
+		for i := 0; i < len(mesh.Triangles); i += t {
+			groups = append(groups, mesh.Triangles[i:i+t])
+		}
+	}
+	var meshes []*Mesh
+	var names []string
+	for i, group := range groups {
+		meshes = append(meshes, NewTriangleMesh(group))
+		names = append(names, fmt.Sprintf("part %d", i))
+	}
+	return meshes, names, nil
+}
+
+func swap(v Vector, n int) Vector {
+	switch n {
+	case 0:
+		return Vector{v.X, v.Y, v.Z}
+	case 1:
+		return Vector{v.X, v.Z, v.Y}
+	case 2:
+		return Vector{v.Y, v.X, v.Z}
+	case 3:
+		return Vector{v.Y, v.Z, v.X}
+	case 4:
+		return Vector{v.Z, v.X, v.Y}
+	case 5:
+		return Vector{v.Z, v.Y, v.X}
+	}
+	return v
+}
+
+func renderViews(path string) error {
+	var done func()
+
+	// load a mesh
+	done = timed("loading mesh")
+	mesh, err := LoadMesh(path)
+	if err != nil {
+		return err
+	}
+	done()
+
+	// fit mesh in a bi-unit cube centered at the origin
+	done = timed("transforming mesh")
+	mesh.MoveTo(Vector{}, Vector{0.5, 0.5, 0.5})
+	done()
+
+	for i := 0; i < 6; i++ {
+		c := viewsCamera
+		c.Eye = swap(c.Eye, i)
+		c.Center = swap(c.Center, i)
+		c.Up = swap(c.Up, i)
+		c.Light = swap(c.Light, i)
+
+		// create a rendering context
+		context := NewContext(c.Width*c.Scale, c.Height*c.Scale)
+		context.ClearColorBufferWith(viewsBackground)
+
+		// render
+		matrix := c.Matrix()
+		shader := NewPhongShader(matrix, c.Light, c.Eye)
+		shader.ObjectColor = viewsColor
+		context.Shader = shader
+		done = timed("rendering mesh")
+		context.DrawMesh(mesh)
+		done()
+
+		context.Shader = NewSolidColorShader(matrix, Black)
+		context.LineWidth = float64(c.Scale * 3)
+		context.DrawMesh(NewCubeOutlineForBox(mesh.BoundingBox()))
+
+		// downsample image for antialiasing
+		done = timed("downsampling image")
+		image := context.Image()
+		image = resize.Resize(uint(c.Width), uint(c.Height), image, resize.Bilinear)
+		done()
+
+		// save image
+		done = timed("writing output")
+		if err := SavePNG(fmt.Sprintf("%s.%d.png", path, i), image); err != nil {
+			return err
+		}
+		done()
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var separateCommand = &command{
+	Name:    "separate",
+	Args:    "mesh1.stl mesh2.stl ...",
+	Summary: "split meshes into parts, keeping cavities with the part around them",
+	Run:     runSeparate,
+}
+
+type separateOptions struct {
+	Epsilon   float64 // vertices closer than this are welded
+	MinVolume float64 // smaller parts are dropped
+	Format    string  // stl or 3mf
+	Cavities  bool    // print the volume of each cavity
+}
+
+func runSeparate(fs *flag.FlagSet, args []string) error {
+	var options separateOptions
+	fs.Float64Var(&options.Epsilon, "epsilon", 1e-4, "weld vertices closer than this, 0 for exact matches only")
+	fs.Float64Var(&options.MinVolume, "min-volume", 0, "drop parts with less volume than this")
+	fs.BoolVar(&options.Cavities, "cavities", false, "print the volume of each cavity kept with its part")
+	fs.StringVar(&options.Format, "format", "stl", "write numbered stl files or one 3mf file with an object per shell")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || options.Epsilon < 0 {
+		return errUsage
+	}
+	if options.Format != "stl" && options.Format != "3mf" {
+		return fmt.Errorf("unknown format: %s", options.Format)
+	}
+	for _, filename := range fs.Args() {
+		if err := separate(filename, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func separate(filename string, options separateOptions) error {
+	mesh, err := LoadMesh(filename)
+	if err != nil {
+		return err
+	}
+
+	var meshes []*Mesh
+	var names []string
+	var dropped int
+	var droppedVolume float64
+	ext := path.Ext(filename)
+	base := filename[:len(filename)-len(ext)]
+	fmt.Println(filename)
+	shells := nestShells(connectedParts(mesh, options.Epsilon))
+	for _, s := range shells {
+		if s.Cavity() {
+			continue
+		}
+		// the cavities may not face inward, so their volumes are subtracted
+		// rather than measuring the combined mesh
+		part := s.Mesh.Copy()
+		volume := s.Volume
+		for _, c := range s.Cavities {
+			part.Add(shells[c].Mesh)
+			volume -= shells[c].Volume
+		}
+		if volume < options.MinVolume {
+			dropped++
+			droppedVolume += volume
+			continue
+		}
+		i := len(meshes)
+		size := s.Box.Size()
+		fmt.Printf("  %4d: %6d triangles, volume %10.3f, %g x %g x %g",
+			i, len(part.Triangles), volume, size.X, size.Y, size.Z)
+		if len(s.Cavities) > 0 {
+			fmt.Printf(", %d cavities", len(s.Cavities))
+		}
+		fmt.Println()
+		if options.Cavities {
+			for _, c := range s.Cavities {
+				fmt.Printf("        cavity volume %10.3f\n", shells[c].Volume)
+			}
+		}
+		meshes = append(meshes, part)
+		names = append(names, fmt.Sprintf("%s.%d", path.Base(base), i))
+	}
+	if dropped > 0 {
+		fmt.Printf("  dropped %d parts with a total volume of %.3f\n", dropped, droppedVolume)
+	}
+
+	if options.Format == "3mf" {
+		return pack3d.Save3MF(base+".3mf", meshes, names, nil)
+	}
+	for i, part := range meshes {
+		if err := part.SaveSTL(fmt.Sprintf("%s.%d.stl", base, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shell is a connected group of triangles and its place in the nesting of
+// shells inside one another.
+type shell struct {
+	Mesh     *Mesh
+	Box      Box
+	Volume   float64 // enclosed volume, regardless of winding
+	Parent   int     // innermost enclosing shell, -1 if none
+	Depth    int     // number of enclosing shells
+	Cavities []int   // enclosed shells that are the inner walls of this one
+}
+
+// Cavity reports whether the shell is the inner wall of a hollow part
+// rather than a part of its own. Walls and parts alternate as shells nest,
+// so a part inside a cavity is a part again.
+func (s *shell) Cavity() bool {
+	return s.Depth%2 == 1
+}
+
+// nestShells finds which shells enclose which by casting rays from a vertex
+// of each shell, and attaches cavities to the shells that enclose them.
+func nestShells(groups [][]*Triangle) []*shell {
+	shells := make([]*shell, len(groups))
+	for i, group := range groups {
+		mesh := NewTriangleMesh(group)
+		shells[i] = &shell{mesh, mesh.BoundingBox(), mesh.Volume(), -1, 0, nil}
+	}
+	for i, inner := range shells {
+		p := inner.Mesh.Triangles[0].V1.Position
+		for j, outer := range shells {
+			if i == j || !outer.Box.ContainsBox(inner.Box) || !pack3d.InsideMesh(outer.Mesh, p) {
+				continue
+			}
+			inner.Depth++
+			// the innermost enclosing shell is the smallest one
+			if inner.Parent < 0 || outer.Volume < shells[inner.Parent].Volume {
+				inner.Parent = j
+			}
+		}
+	}
+	for i, s := range shells {
+		if s.Cavity() {
+			parent := shells[s.Parent]
+			parent.Cavities = append(parent.Cavities, i)
+		}
+	}
+	return shells
+}
+
+// connectedParts groups the triangles of mesh into shells that share
+// vertices. Vertices closer than epsilon are welded together first.
+func connectedParts(mesh *Mesh, epsilon float64) [][]*Triangle {
+	welder := newVertexWelder(epsilon)
+	ids := make([][3]int, len(mesh.Triangles))
+	for i, t := range mesh.Triangles {
+		ids[i] = [3]int{welder.ID(t.V1.Position), welder.ID(t.V2.Position), welder.ID(t.V3.Position)}
+	}
+
+	// union the vertices of every triangle
+	parent := make([]int, welder.Count())
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for _, id := range ids {
+		a := find(id[0])
+		parent[find(id[1])] = a
+		parent[find(id[2])] = a
+	}
+
+	var groups [][]*Triangle
+	lookup := make(map[int]int)
+	for i, t := range mesh.Triangles {
+		root := find(ids[i][0])
+		j, ok := lookup[root]
+		if !ok {
+			j = len(groups)
+			lookup[root] = j
+			groups = append(groups, nil)
+		}
+		groups[j] = append(groups[j], t)
+	}
+	return groups
+}
+
+// vertexWelder assigns the same id to vertices closer than epsilon, using a
+// spatial hash of cells epsilon wide.
+type vertexWelder struct {
+	epsilon   float64
+	exact     map[Vector]int
+	cells     map[[3]int64][]int
+	positions []Vector
+}
+
+func newVertexWelder(epsilon float64) *vertexWelder {
+	w := &vertexWelder{}
+	w.epsilon = epsilon
+	w.exact = make(map[Vector]int)
+	w.cells = make(map[[3]int64][]int)
+	return w
+}
+
+func (w *vertexWelder) Count() int {
+	return len(w.positions)
+}
+
+func (w *vertexWelder) cell(p Vector) [3]int64 {
+	return [3]int64{
+		int64(math.Floor(p.X / w.epsilon)),
+		int64(math.Floor(p.Y / w.epsilon)),
+		int64(math.Floor(p.Z / w.epsilon)),
+	}
+}
+
+func (w *vertexWelder) ID(p Vector) int {
+	if id, ok := w.exact[p]; ok {
+		return id
+	}
+	id := -1
+	var cell [3]int64
+	if w.epsilon > 0 {
+		// a vertex within epsilon is in this cell or a neighbor
+		cell = w.cell(p)
+		for dx := int64(-1); dx <= 1 && id < 0; dx++ {
+			for dy := int64(-1); dy <= 1 && id < 0; dy++ {
+				for dz := int64(-1); dz <= 1 && id < 0; dz++ {
+					for _, i := range w.cells[[3]int64{cell[0] + dx, cell[1] + dy, cell[2] + dz}] {
+						if w.positions[i].Distance(p) <= w.epsilon {
+							id = i
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	if id < 0 {
+		id = len(w.positions)
+		w.positions = append(w.positions, p)
+		if w.epsilon > 0 {
+			w.cells[cell] = append(w.cells[cell], id)
+		}
+	}
+	w.exact[p] = id
+	return id
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var serveCommand = &command{
+	Name:    "serve",
+	Args:    "",
+	Summary: "run an HTTP API for submitting, monitoring and cancelling pack jobs",
+	Run:     runServe,
+}
+
+func runServe(fs *flag.FlagSet, args []string) error {
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of jobs packed at the same time")
+	dir := fs.String("dir", "", "directory for uploaded meshes (default a new temporary directory)")
+	maxUpload := fs.Int64("max-upload", 256, "maximum upload size in MB")
+	expire := fs.Duration("expire", time.Hour, "forget finished jobs and delete their files after this long")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *workers < 1 {
+		return errUsage
+	}
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	if *dir == "" {
+		var err error
+		*dir, err = ioutil.TempDir("", "pack3d-serve")
+		if err != nil {
+			return err
+		}
+	}
+	s := newServer(*dir, *maxUpload<<20)
+	for i := 0; i < *workers; i++ {
+		go s.work()
+	}
+	go s.expireJobs(*expire)
+	log.Printf("serving on http://%s, storing jobs in %s", *addr, *dir)
+	return http.ListenAndServe(*addr, s)
+}
+
+// Job states reported by the API.
+const (
+	stateQueued   = "queued"
+	stateRunning  = "running"
+	stateDone     = "done"
+	stateCanceled = "canceled"
+	stateFailed   = "failed"
+)
+
+// serveJob is a submitted job and its progress. Everything below mu is
+// guarded by it.
+type serveJob struct {
+	ID      string
+	Dir     string
+	Job     *pack3d.Job
+	Created time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	state     string
+	err       error
+	ended     time.Time // when the job finished
+	attempt   int
+	step      int
+	steps     int
+	energy    float64
+	best      *pack3d.Model
+	stats     pack3d.RunStats
+	listeners map[chan jobStatus]bool
+}
+
+type jobStatus struct {
+	ID       string      `json:"id"`
+	State    string      `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Created  time.Time   `json:"created"`
+	Attempt  int         `json:"attempt"`
+	Step     int         `json:"step"`
+	Steps    int         `json:"steps"`
+	Energy   float64     `json:"energy,omitempty"`   // best energy of the current attempt
+	Best     float64     `json:"best,omitempty"`     // best energy of the job
+	Size     *[3]float64 `json:"size,omitempty"`     // bounding box of the best packing
+	Schedule string      `json:"schedule,omitempty"` // cooling schedule that found the best packing
+}
+
+func (j *serveJob) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.statusLocked()
+}
+
+func (j *serveJob) statusLocked() jobStatus {
+	s := jobStatus{
+		ID:      j.ID,
+		State:   j.state,
+		Created: j.Created,
+		Attempt: j.attempt,
+		Step:    j.step,
+		Steps:   j.steps,
+		Energy:  j.energy,
+	}
+	if j.err != nil {
+		s.Error = j.err.Error()
+	}
+	if j.best != nil {
+		s.Best = j.best.Energy()
+		size := j.best.BoundingBox().Size()
+		s.Size = &[3]float64{size.X, size.Y, size.Z}
+		s.Schedule = j.best.Cooling.String()
+	}
+	return s
+}
+
+func (j *serveJob) finished() bool {
+	return j.state == stateDone || j.state == stateCanceled || j.state == stateFailed
+}
+
+// update changes the job under its lock and sends the new status to every
+// listener. Slow listeners miss updates rather than blocking the job.
+func (j *serveJob) update(f func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f()
+	status := j.statusLocked()
+	for ch := range j.listeners {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
+
+// subscribe returns the current status and a channel of later ones. The
+// channel is nil if the job has already finished.
+func (j *serveJob) subscribe() (jobStatus, chan jobStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished() {
+		return j.statusLocked(), nil
+	}
+	ch := make(chan jobStatus, 16)
+	j.listeners[ch] = true
+	return j.statusLocked(), ch
+}
+
+func (j *serveJob) unsubscribe(ch chan jobStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.listeners, ch)
+}
+
+func (j *serveJob) run() {
+	j.update(func() {
+		j.state = stateRunning
+	})
+	j.Job.Progress = func(step, steps int, energy float64) {
+		j.update(func() {
+			if step == 0 {
+				j.attempt++
+			}
+			j.step = step
+			j.steps = steps
+			j.energy = energy
+		})
+	}
+	_, err := j.Job.Run(j.ctx, func(model *pack3d.Model) {
+		j.mu.Lock()
+		j.best = model
+		j.stats = j.Job.Stats
+		j.mu.Unlock()
+	})
+	j.update(func() {
+		switch {
+		case err != nil:
+			j.state = stateFailed
+			j.err = err
+		case j.ctx.Err() == context.Canceled:
+			j.state = stateCanceled
+		default:
+			j.state = stateDone
+		}
+		j.ended = time.Now()
+		for ch := range j.listeners {
+			close(ch)
+		}
+		j.listeners = nil
+	})
+	j.cancel()
+}
+
+type server struct {
+	dir       string
+	maxUpload int64
+	queue     chan *serveJob
+
+	mu     sync.Mutex
+	jobs   map[string]*serveJob
+	order  []*serveJob
+	nextID int
+}
+
+func newServer(dir string, maxUpload int64) *server {
+	s := &server{}
+	s.dir = dir
+	s.maxUpload = maxUpload
+	s.queue = make(chan *serveJob, 1024)
+	s.jobs = make(map[string]*serveJob)
+	s.nextID = 1
+	return s
+}
+
+// expireInterval is how often expireJobs looks for old jobs.
+const expireInterval = time.Minute
+
+// expireJobs forgets jobs that finished more than ttl ago and deletes their
+// uploads, so that a long running server does not grow without bound.
+func (s *server) expireJobs(ttl time.Duration) {
+	for range time.Tick(expireInterval) {
+		s.pruneJobs(time.Now().Add(-ttl))
+	}
+}
+
+// pruneJobs forgets the jobs that finished before t.
+func (s *server) pruneJobs(t time.Time) {
+	var expired []*serveJob
+	s.mu.Lock()
+	order := s.order[:0]
+	for _, j := range s.order {
+		j.mu.Lock()
+		old := j.finished() && j.ended.Before(t)
+		j.mu.Unlock()
+		if old {
+			expired = append(expired, j)
+			delete(s.jobs, j.ID)
+		} else {
+			order = append(order, j)
+		}
+	}
+	for i := len(order); i < len(s.order); i++ {
+		s.order[i] = nil
+	}
+	s.order = order
+	s.mu.Unlock()
+	for _, j := range expired {
+		os.RemoveAll(j.Dir)
+		log.Printf("job %s: expired", j.ID)
+	}
+}
+
+func (s *server) work() {
+	for j := range s.queue {
+		if j.ctx.Err() != nil {
+			continue
+		}
+		j.run()
+	}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case "GET":
+			s.listJobs(w, r)
+		case "POST":
+			s.createJob(w, r)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+		return
+	}
+	s.mu.Lock()
+	j := s.jobs[parts[1]]
+	s.mu.Unlock()
+	if j == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 2 {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, j.status())
+		case "DELETE":
+			s.cancelJob(w, j)
+		default:
+			methodNotAllowed(w, "GET, DELETE")
+		}
+		return
+	}
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	switch parts[2] {
+	case "events":
+		s.streamJob(w, r, j)
+	case "best.stl", "best.3mf", "best.json", "best.csv":
+		s.downloadJob(w, r, j, filepath.Ext(parts[2]))
+	case "report.txt", "report.json", "report.html":
+		s.reportJob(w, r, j, filepath.Ext(parts[2]))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) listJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := append([]*serveJob(nil), s.order...)
+	s.mu.Unlock()
+	result := make([]jobStatus, len(jobs))
+	for i, j := range jobs {
+		result[i] = j.status()
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// createJob accepts a multipart form with the job description in a "job"
+// field and every mesh it refers to as a file upload. Part files are
+// matched to uploads by name.
+func (s *server) createJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	description := r.FormValue("job")
+	if description == "" {
+		http.Error(w, "missing job field", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	id := strconv.Itoa(s.nextID)
+	s.nextID++
+	s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, id)
+	job, code, err := prepareJob(r.MultipartForm, description, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &serveJob{
+		ID:        id,
+		Dir:       dir,
+		Job:       job,
+		Created:   time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		state:     stateQueued,
+		listeners: make(map[chan jobStatus]bool),
+	}
+	select {
+	case s.queue <- j:
+	default:
+		cancel()
+		os.RemoveAll(dir)
+		http.Error(w, "too many queued jobs", http.StatusServiceUnavailable)
+		return
+	}
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.order = append(s.order, j)
+	s.mu.Unlock()
+	log.Printf("job %s: queued with %d parts", id, len(job.Parts))
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusCreated, j.status())
+}
+
+// prepareJob saves the uploaded meshes to dir and parses the job that uses
+// them, returning an HTTP status code with any error.
+func prepareJob(form *multipart.Form, description, dir string) (*pack3d.Job, int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	uploaded := make(map[string]bool)
+	for _, headers := range form.File {
+		for _, header := range headers {
+			name := filepath.Base(header.Filename)
+			if name == "." || name == ".." || name == string(filepath.Separator) {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid file name %q", header.Filename)
+			}
+			if err := saveUpload(header, filepath.Join(dir, name)); err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			uploaded[name] = true
+		}
+	}
+	// parts must be uploads in dir before ParseJob looks for their files, so
+	// that clients cannot probe the server's file system
+	var parts struct {
+		Parts []struct {
+			File string `json:"file"`
+		} `json:"parts"`
+	}
+	if err := json.Unmarshal([]byte(description), &parts); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid job: %v", err)
+	}
+	for i, part := range parts.Parts {
+		if part.File == "" {
+			continue
+		}
+		if !uploadName(part.File) || filepath.Dir(filepath.Join(dir, part.File)) != filepath.Clean(dir) {
+			return nil, http.StatusBadRequest, fmt.Errorf("parts[%d].file: %q must be the name of an upload", i, part.File)
+		}
+		if !uploaded[part.File] {
+			return nil, http.StatusBadRequest, fmt.Errorf("parts[%d].file: %s was not uploaded", i, part.File)
+		}
+	}
+	job, err := pack3d.ParseJob([]byte(description), dir)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(job.Outputs) != 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("outputs: not allowed, download results from the job instead")
+	}
+	// an endless job would hold a worker forever
+	if job.Attempts == 0 && job.TimeBudget == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("time_budget: required unless attempts is set")
+	}
+	return job, 0, nil
+}
+
+// uploadName reports whether name is a plain file name, with no directory
+// that could lead out of the job's upload directory.
+func uploadName(name string) bool {
+	return !filepath.IsAbs(name) && !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+func saveUpload(header *multipart.FileHeader, path string) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// cancelJob stops a running job, keeping its best packing for download, or
+// removes a queued one from the queue.
+func (s *server) cancelJob(w http.ResponseWriter, j *serveJob) {
+	j.cancel()
+	j.update(func() {
+		if j.state == stateQueued {
+			j.state = stateCanceled
+			j.ended = time.Now()
+			for ch := range j.listeners {
+				close(ch)
+			}
+			j.listeners = nil
+		}
+	})
+	log.Printf("job %s: canceled", j.ID)
+	writeJSON(w, http.StatusOK, j.status())
+}
+
+// streamJob sends status updates as server-sent events until the job
+// finishes or the client goes away.
+func (s *server) streamJob(w http.ResponseWriter, r *http.Request, j *serveJob) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	status, ch := j.subscribe()
+	if ch != nil {
+		defer j.unsubscribe(ch)
+	}
+	for {
+		data, _ := json.Marshal(status)
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		flusher.Flush()
+		if ch == nil {
+			return
+		}
+		select {
+		case next, ok := <-ch:
+			if !ok {
+				status, ch = j.status(), nil
+				continue
+			}
+			status = next
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// downloadJob writes the best packing found so far in the format given by
+// ext.
+func (s *server) downloadJob(w http.ResponseWriter, r *http.Request, j *serveJob, ext string) {
+	j.mu.Lock()
+	best := j.best
+	j.mu.Unlock()
+	if best == nil {
+		http.Error(w, "no packing has been found yet", http.StatusNotFound)
+		return
+	}
+	file, err := ioutil.TempFile(j.Dir, "best-*"+ext)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+	if err := best.Save(path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err = os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	name := fmt.Sprintf("job-%s%s", j.ID, ext)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, time.Now(), file)
+}
+
+// reportJob writes the report of the best packing found so far in the
+// format given by ext.
+func (s *server) reportJob(w http.ResponseWriter, r *http.Request, j *serveJob, ext string) {
+	j.mu.Lock()
+	best, stats := j.best, j.stats
+	j.mu.Unlock()
+	if best == nil {
+		http.Error(w, "no packing has been found yet", http.StatusNotFound)
+		return
+	}
+	report := pack3d.NewReport(best, stats)
+	switch ext {
+	case ".txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		report.WriteText(w)
+	case ".json":
+		writeJSON(w, http.StatusOK, report)
+	case ".html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		report.WriteHTML(w, renderModel(best, 1024, 768))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer returns a server with no workers, so that created jobs stay
+// queued, and a function that sends it a request.
+func testServer(t *testing.T) (*server, func(method, path string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder) {
+	dir, err := ioutil.TempDir("", "pack3d-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(dir, 1<<20)
+	do := func(method, path string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+		if body == nil {
+			body = &bytes.Buffer{}
+		}
+		r := httptest.NewRequest(method, path, body)
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	return s, do
+}
+
+// jobForm returns a multipart form with the job description and an upload
+// for each of the files.
+func jobForm(job string, files ...string) (*bytes.Buffer, string) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if job != "" {
+		w.WriteField("job", job)
+	}
+	for _, name := range files {
+		f, _ := w.CreateFormFile("mesh", name)
+		f.Write([]byte("solid test\nendsolid test\n"))
+	}
+	w.Close()
+	return &b, w.FormDataContentType()
+}
+
+func TestCreateJob(t *testing.T) {
+	s, do := testServer(t)
+	defer os.RemoveAll(s.dir)
+	tests := []struct {
+		name  string
+		job   string
+		files []string
+		code  int
+		err   string
+	}{
+		{"valid", `{"parts": [{"file": "a.stl", "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusCreated, ""},
+		{"no job", "", []string{"a.stl"}, http.StatusBadRequest, "missing job field"},
+		{"trailing data", `{"parts": [{"file": "/etc/passwd", "count": 1}], "attempts": 1} x`, []string{"a.stl"}, http.StatusBadRequest, "invalid job"},
+		{"wrong type", `{"parts": [{"file": 1, "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "invalid job"},
+		{"absolute path", `{"parts": [{"file": "/etc/passwd", "count": 1}], "attempts": 1}`, []string{"passwd"}, http.StatusBadRequest, "must be the name of an upload"},
+		{"parent directory", `{"parts": [{"file": "../a.stl", "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "must be the name of an upload"},
+		{"subdirectory", `{"parts": [{"file": "sub/a.stl", "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "must be the name of an upload"},
+		{"backslash", `{"parts": [{"file": "sub\\a.stl", "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "must be the name of an upload"},
+		{"not uploaded", `{"parts": [{"file": "b.stl", "count": 1}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "b.stl was not uploaded"},
+		{"field error", `{"parts": [{"file": "a.stl", "count": 0}], "attempts": 1}`, []string{"a.stl"}, http.StatusBadRequest, "parts[0].count: must be at least 1"},
+		{"outputs", `{"parts": [{"file": "a.stl", "count": 1}], "attempts": 1, "outputs": ["out.stl"]}`, []string{"a.stl"}, http.StatusBadRequest, "outputs: not allowed"},
+		{"endless", `{"parts": [{"file": "a.stl", "count": 1}]}`, []string{"a.stl"}, http.StatusBadRequest, "time_budget: required"},
+	}
+	for _, test := range tests {
+		body, contentType := jobForm(test.job, test.files...)
+		w := do("POST", "/jobs", body, contentType)
+		if w.Code != test.code {
+			t.Errorf("%s: status %d, want %d: %s", test.name, w.Code, test.code, w.Body.String())
+			continue
+		}
+		if test.err != "" && !strings.Contains(w.Body.String(), test.err) {
+			t.Errorf("%s: %q, want %q", test.name, w.Body.String(), test.err)
+		}
+	}
+	// only the valid job keeps its upload directory
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "1" {
+		t.Errorf("upload directory holds %d entries, want only the valid job", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "1", "a.stl")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServeJob(t *testing.T) {
+	s, do := testServer(t)
+	defer os.RemoveAll(s.dir)
+	body, contentType := jobForm(`{"parts": [{"file": "a.stl", "count": 1}], "attempts": 1}`, "a.stl")
+	if w := do("POST", "/jobs", body, contentType); w.Code != http.StatusCreated || w.Header().Get("Location") != "/jobs/1" {
+		t.Fatalf("status %d at %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	tests := []struct {
+		method, path string
+		code         int
+		state        string
+	}{
+		{"GET", "/jobs/1", http.StatusOK, stateQueued},
+		{"GET", "/jobs/2", http.StatusNotFound, ""},
+		{"GET", "/jobs/1/best.obj", http.StatusNotFound, ""},
+		{"GET", "/other", http.StatusNotFound, ""},
+		{"PUT", "/jobs", http.StatusMethodNotAllowed, ""},
+		{"POST", "/jobs/1", http.StatusMethodNotAllowed, ""},
+		{"DELETE", "/jobs/1/best.stl", http.StatusMethodNotAllowed, ""},
+		{"DELETE", "/jobs/1", http.StatusOK, stateCanceled},
+		{"GET", "/jobs/1", http.StatusOK, stateCanceled},
+	}
+	for _, test := range tests {
+		w := do(test.method, test.path, nil, "")
+		if w.Code != test.code {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.path, w.Code, test.code)
+			continue
+		}
+		if test.state == "" {
+			continue
+		}
+		var status jobStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Errorf("%s %s: %v", test.method, test.path, err)
+		} else if status.ID != "1" || status.State != test.state {
+			t.Errorf("%s %s: job %s is %s, want job 1 %s", test.method, test.path, status.ID, status.State, test.state)
+		}
+	}
+
+	// finished jobs are forgotten along with their uploads
+	s.pruneJobs(time.Now().Add(time.Minute))
+	if w := do("GET", "/jobs", nil, ""); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("jobs after pruning: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "1")); !os.IsNotExist(err) {
+		t.Errorf("upload directory of a pruned job: %v", err)
+	}
+}
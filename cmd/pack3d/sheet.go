@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/gg"
+)
+
+type sheetView struct {
+	Name      string
+	Direction Vector
+	Axes      [2]int // dimensions of the result visible in the view
+}
+
+var sheetViews = []sheetView{
+	{"front", V(0, -1, 0), [2]int{0, 2}},
+	{"side", V(1, 0, 0), [2]int{1, 2}},
+	{"top", V(0, 0, 1), [2]int{0, 1}},
+	{"iso", V(1, -1.5, 1), [2]int{-1, -1}},
+}
+
+const sheetHeader = 90 // height of the text above the views in pixels
+
+// sheetSummary describes a packing result for the contact sheet header.
+type sheetSummary struct {
+	Title       string
+	Items       int
+	Size        Vector // bounding box of the items
+	Utilization float64
+	Volume      Vector // build volume, zero if none
+}
+
+func summarize(title string, s *scene) sheetSummary {
+	box := EmptyBox
+	var volume float64
+	for _, mesh := range s.Meshes {
+		box = box.Extend(mesh.BoundingBox())
+		volume += math.Abs(mesh.Volume())
+	}
+	summary := sheetSummary{Title: title, Items: len(s.Meshes), Size: box.Size()}
+	if s.Volume != EmptyBox {
+		summary.Volume = s.Volume.Size()
+		summary.Utilization = volume / s.Volume.Volume()
+	} else if box.Volume() > 0 {
+		summary.Utilization = volume / box.Volume()
+	}
+	return summary
+}
+
+func (summary sheetSummary) Lines() []string {
+	size := summary.Size
+	lines := []string{
+		summary.Title,
+		fmt.Sprintf("%d items, %.1f x %.1f x %.1f mm", summary.Items, size.X, size.Y, size.Z),
+	}
+	if v := summary.Volume; v != (Vector{}) {
+		lines = append(lines, fmt.Sprintf("%.1f%% of the %g x %g x %g mm build volume",
+			summary.Utilization*100, v.X, v.Y, v.Z))
+	} else {
+		lines = append(lines, fmt.Sprintf("%.1f%% of the bounding box", summary.Utilization*100))
+	}
+	return lines
+}
+
+func component(v Vector, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// renderSheet renders the front, side, top and iso views of s in a 2x2
+// grid of cells of the given size, annotated with the summary. font is the
+// path of a TrueType font, or empty for the built in one.
+func renderSheet(s scene, summary sheetSummary, width, height int, fovy float64, font string) (image.Image, error) {
+	dc := gg.NewContext(width*2, height*2+sheetHeader)
+	if font != "" {
+		if err := dc.LoadFontFace(font, 16); err != nil {
+			return nil, err
+		}
+	}
+	b := s.Background
+	dc.SetRGB(b.R, b.G, b.B)
+	dc.Clear()
+	dc.SetRGB(0, 0, 0)
+	for i, line := range summary.Lines() {
+		dc.DrawString(line, 12, float64(24+i*24))
+	}
+
+	bounds := s.Bounds()
+	for i, view := range sheetViews {
+		done := timed(fmt.Sprintf("rendering %s view", view.Name))
+		s.Camera = fitCamera(bounds, view.Direction, fovy, width, height, s.Camera.Scale)
+		x := (i % 2) * width
+		y := (i/2)*height + sheetHeader
+		dc.DrawImage(s.Render(), x, y)
+		done()
+
+		label := view.Name
+		if view.Axes[0] >= 0 {
+			label = fmt.Sprintf("%s  %.1f x %.1f mm", view.Name,
+				component(summary.Size, view.Axes[0]), component(summary.Size, view.Axes[1]))
+		}
+		dc.SetRGB(0, 0, 0)
+		dc.DrawString(label, float64(x+12), float64(y+height-12))
+		dc.SetRGBA(0, 0, 0, 0.25)
+		dc.SetLineWidth(1)
+		dc.DrawRectangle(float64(x)+0.5, float64(y)+0.5, float64(width-1), float64(height-1))
+		dc.Stroke()
+	}
+	return dc.Image(), nil
+}
+
+// turntable calls frame with n views of s from directions rotated about the
+// Z axis, starting at dir.
+func turntable(s scene, dir Vector, fovy float64, n int, frame func(i int, image image.Image) error) error {
+	bounds := s.Bounds()
+	c := s.Camera
+	for i := 0; i < n; i++ {
+		m := Rotate(V(0, 0, 1), 2*math.Pi*float64(i)/float64(n))
+		s.Camera = fitCamera(bounds, m.MulDirection(dir), fovy, c.Width, c.Height, c.Scale)
+		if err := frame(i, s.Render()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var sortCommand = &command{
+	Name:    "sort",
+	Args:    "result.json|result.3mf|result.stl",
+	Summary: "put the items of a packing result in build order, for depowdering and render -animate",
+	Run:     runSort,
+}
+
+// sortKey orders items by one measure of their bounding box, or by name.
+type sortKey struct {
+	Name       string
+	Descending bool
+}
+
+var sortKeyNames = []string{"bottom", "top", "x", "y", "corner", "name"}
+
+// parseSortKeys parses a comma separated list of keys, each optionally
+// prefixed with - to sort in descending order.
+func parseSortKeys(s string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		key := sortKey{strings.TrimPrefix(field, "-"), strings.HasPrefix(field, "-")}
+		ok := false
+		for _, name := range sortKeyNames {
+			ok = ok || key.Name == name
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key %q, expected one of %s", field, strings.Join(sortKeyNames, ", "))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (key sortKey) value(box Box, corner Vector) float64 {
+	switch key.Name {
+	case "bottom":
+		return box.Min.Z
+	case "top":
+		return box.Max.Z
+	case "x":
+		return box.Min.X
+	case "y":
+		return box.Min.Y
+	case "corner":
+		return box.Anchor(Vector{0.5, 0.5, 0.5}).Distance(corner)
+	}
+	return 0
+}
+
+// compareNatural compares two names, taking runs of digits as numbers so
+// that "part 2" comes before "part 10". It returns -1, 0 or 1.
+func compareNatural(a, b string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			i, j := 0, 0
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return strings.Compare(a, b)
+}
+
+// buildOrder returns the indexes of the items sorted by keys. Distances are
+// measured from corner. Ties keep their original order.
+func buildOrder(meshes []*Mesh, names []string, keys []sortKey, corner Vector) []int {
+	boxes := make([]Box, len(meshes))
+	order := make([]int, len(meshes))
+	for i, mesh := range meshes {
+		boxes[i] = mesh.BoundingBox()
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		for _, key := range keys {
+			var less, greater bool
+			if key.Name == "name" {
+				c := compareNatural(names[a], names[b])
+				less, greater = c < 0, c > 0
+			} else {
+				va, vb := key.value(boxes[a], corner), key.value(boxes[b], corner)
+				less, greater = va < vb, va > vb
+			}
+			if key.Descending {
+				less, greater = greater, less
+			}
+			if less || greater {
+				return less
+			}
+		}
+		return false
+	})
+	return order
+}
+
+// sortBottomUp sorts meshes by the (z, x, y) of their bounding box minimum,
+// the order in which they are printed.
+func sortBottomUp(meshes []*Mesh) {
+	keys := []sortKey{{"bottom", false}, {"x", false}, {"y", false}}
+	sorted := make([]*Mesh, len(meshes))
+	for i, j := range buildOrder(meshes, make([]string, len(meshes)), keys, Vector{}) {
+		sorted[i] = meshes[j]
+	}
+	copy(meshes, sorted)
+}
+
+func runSort(fs *flag.FlagSet, args []string) error {
+	count := fs.Int("n", 0, "split an stl result into N equal-size items instead of its connected parts")
+	output := fs.String("o", "", "output manifest, 3mf or stl file (default result-sorted with the input's format)")
+	by := fs.String("by", "bottom,x,y", "comma separated sort keys: "+strings.Join(sortKeyNames, ", ")+", prefixed with - to reverse")
+	cornerFlag := fs.String("corner", "", "x,y,z corner for the corner key (default the minimum corner of the result)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *count < 0 {
+		return errUsage
+	}
+	keys, err := parseSortKeys(*by)
+	if err != nil {
+		return err
+	}
+
+	input := fs.Arg(0)
+	ext := strings.ToLower(filepath.Ext(input))
+	if *output == "" {
+		*output = strings.TrimSuffix(input, filepath.Ext(input)) + "-sorted" + filepath.Ext(input)
+	}
+	outputExt := strings.ToLower(filepath.Ext(*output))
+	if outputExt == ".json" && ext != ".json" {
+		return fmt.Errorf("a manifest can only be written when sorting a manifest")
+	}
+
+	meshes, names, err := loadResult(input, *count)
+	if err != nil {
+		return err
+	}
+	if len(meshes) == 0 {
+		return fmt.Errorf("%s has no items", input)
+	}
+
+	bounds := EmptyBox
+	for _, mesh := range meshes {
+		bounds = bounds.Extend(mesh.BoundingBox())
+	}
+	corner := bounds.Min
+	if *cornerFlag != "" {
+		corner, err = parseVector(*cornerFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	order := buildOrder(meshes, names, keys, corner)
+	sortedMeshes := make([]*Mesh, len(order))
+	sortedNames := make([]string, len(order))
+	for i, j := range order {
+		sortedMeshes[i] = meshes[j]
+		sortedNames[i] = names[j]
+		box := meshes[j].BoundingBox()
+		fmt.Printf("%4d: %-40s z %g to %g\n", i, names[j], box.Min.Z, box.Max.Z)
+	}
+
+	switch outputExt {
+	case ".json":
+		manifest, err := pack3d.LoadManifest(input)
+		if err != nil {
+			return err
+		}
+		items := make([]pack3d.ManifestItem, len(order))
+		for i, j := range order {
+			items[i] = manifest.Items[j]
+		}
+		manifest.Items = items
+		manifest.Order = *by
+		return manifest.Save(*output)
+	case ".3mf":
+		return pack3d.Save3MF(*output, sortedMeshes, sortedNames, nil)
+	case ".stl":
+		result := NewEmptyMesh()
+		for _, mesh := range sortedMeshes {
+			result.Add(mesh)
+		}
+		return result.SaveSTL(*output)
+	}
+	return fmt.Errorf("unsupported output format: %s", *output)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/fogleman/fauxgl"
+)
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"part 2", "part 10", -1},
+		{"part 10", "part 10", 0},
+		{"part 007", "part 7", 0},
+		{"part 08", "part 9", -1},
+		{"a", "b", -1},
+		{"b1", "a2", 1},
+		{"part", "part 1", -1},
+		{"x9", "x09y", -1},
+		{"12345678901234567890", "9", 1},
+		{"", "", 0},
+	}
+	for _, test := range tests {
+		if got := compareNatural(test.a, test.b); got != test.want {
+			t.Errorf("compareNatural(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := compareNatural(test.b, test.a); got != -test.want {
+			t.Errorf("compareNatural(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestParseSortKeys(t *testing.T) {
+	keys, err := parseSortKeys("bottom, -top,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sortKey{{"bottom", false}, {"top", true}, {"name", false}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+	if _, err := parseSortKeys("bottom,size"); err == nil {
+		t.Error("no error for an unknown key")
+	}
+}
+
+func TestBuildOrder(t *testing.T) {
+	var meshes []*Mesh
+	names := []string{"part 10", "part 2", "part 1", "part 3"}
+	for _, min := range []Vector{{5, 0, 2}, {0, 0, 2}, {9, 9, 0}, {1, 1, 0}} {
+		meshes = append(meshes, NewCubeForBox(Box{min, min.AddScalar(1)}))
+	}
+	tests := []struct {
+		by   string
+		want []int
+	}{
+		{"bottom,x,y", []int{3, 2, 1, 0}},
+		{"-top,name", []int{1, 0, 2, 3}},
+		{"corner", []int{3, 1, 0, 2}},
+		{"name", []int{2, 1, 3, 0}},
+		// ties keep their original order
+		{"bottom", []int{2, 3, 0, 1}},
+	}
+	for _, test := range tests {
+		keys, err := parseSortKeys(test.by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order := buildOrder(meshes, names, keys, Vector{}); !reflect.DeepEqual(order, test.want) {
+			t.Errorf("%s: order %v, want %v", test.by, order, test.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/pack3d"
+)
+
+var validateCommand = &command{
+	Name:    "validate",
+	Args:    "result.json|result.3mf|result.stl",
+	Summary: "check that the items of a packing result keep their clearance and fit the build volume",
+	Run:     runValidate,
+}
+
+func runValidate(fs *flag.FlagSet, args []string) error {
+	clearance := fs.Float64("clearance", 0, "minimum distance between items in mm, 0 only forbids contact")
+	volumeFlag := fs.String("volume", "", "build volume like 165x165x320 with its corner at the origin")
+	count := fs.Int("n", 0, "split an stl result into N equal-size items instead of its connected parts")
+	detail := fs.Int("detail", 6, "bvh tree depth used to skip distant pairs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *clearance < 0 {
+		return errUsage
+	}
+
+	var volume fauxgl.Box
+	if *volumeFlag != "" {
+		size, err := parseSize(*volumeFlag)
+		if err != nil {
+			return err
+		}
+		volume = fauxgl.Box{fauxgl.Vector{}, size}
+	}
+
+	var done func()
+
+	done = timed("loading result")
+	meshes, names, err := loadResult(fs.Arg(0), *count)
+	if err != nil {
+		return err
+	}
+	done()
+	fmt.Printf("  %d items\n", len(meshes))
+
+	var outside int
+	if volume != fauxgl.EmptyBox {
+		for i, mesh := range meshes {
+			box := mesh.BoundingBox()
+			if !volume.ContainsBox(box) {
+				outside++
+				over := box.Min.Sub(volume.Min).Negate().Max(box.Max.Sub(volume.Max))
+				fmt.Printf("outside: %d (%s) extends %s beyond the build volume\n",
+					i, names[i], formatVector(over.Max(fauxgl.Vector{})))
+			}
+		}
+	}
+
+	done = timed("checking clearance")
+	collisions := pack3d.FindCollisions(meshes, *clearance, *detail)
+	done()
+	for _, c := range collisions {
+		what := fmt.Sprintf("%.3f mm apart", c.Distance)
+		if c.Distance == 0 {
+			what = "intersect"
+		}
+		fmt.Printf("collision: %d (%s) and %d (%s) %s in %s - %s\n",
+			c.I, names[c.I], c.J, names[c.J], what, formatVector(c.Region.Min), formatVector(c.Region.Max))
+	}
+
+	if len(collisions) > 0 || outside > 0 {
+		return fmt.Errorf("%d collisions, %d items outside the build volume", len(collisions), outside)
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func formatVector(v fauxgl.Vector) string {
+	return fmt.Sprintf("(%.3f, %.3f, %.3f)", v.X, v.Y, v.Z)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/fogleman/fauxgl"
+)
+
+// saveResult writes the boxes to an stl file as one mesh. Boxes flagged as
+// cavities have their winding reversed, so that they are the inner walls
+// of the box around them.
+func saveResult(t *testing.T, dir, name string, boxes []Box, cavities ...int) string {
+	mesh := NewEmptyMesh()
+	for i, box := range boxes {
+		cube := NewCubeForBox(box)
+		for _, c := range cavities {
+			if c == i {
+				for _, t := range cube.Triangles {
+					t.V1, t.V2 = t.V2, t.V1
+				}
+			}
+		}
+		mesh.Add(cube)
+	}
+	path := filepath.Join(dir, name)
+	if err := mesh.SaveSTL(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pack3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hollow := []Box{{Vector{0, 0, 0}, Vector{20, 20, 20}}, {Vector{2, 2, 2}, Vector{18, 18, 18}}}
+	tests := []struct {
+		name  string
+		boxes []Box
+		args  []string
+		err   string
+	}{
+		{"apart", append(hollow, Box{Vector{30, 0, 0}, Vector{40, 10, 10}}), nil, ""},
+		{"in the cavity", append(hollow, Box{Vector{8, 8, 8}, Vector{12, 12, 12}}), []string{"-clearance", "1"}, ""},
+		{"near the inner wall", append(hollow, Box{Vector{3, 8, 8}, Vector{12, 12, 12}}), []string{"-clearance", "1.5"}, "1 collisions, 0 items outside"},
+		{"near the outer wall", append(hollow, Box{Vector{21, 0, 0}, Vector{30, 10, 10}}), []string{"-clearance", "1.5"}, "1 collisions, 0 items outside"},
+		{"outside the volume", append(hollow, Box{Vector{30, 0, 0}, Vector{40, 10, 10}}), []string{"-volume", "35x35x35"}, "0 collisions, 1 items outside"},
+	}
+	for _, test := range tests {
+		path := saveResult(t, dir, test.name+".stl", test.boxes, 1)
+		meshes, _, err := loadResult(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the cavity stays with its part
+		if len(meshes) != 2 {
+			t.Errorf("%s: %d items, want 2", test.name, len(meshes))
+		}
+		fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+		err = runValidate(fs, append(test.args, path))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package pack3d
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
@@ -17,9 +18,34 @@ type Annealable interface {
 }
 
 func Anneal(state Annealable, maxTemp, minTemp float64, steps int, callback AnnealCallback) Annealable {
-	start := time.Now()
-	factor := -math.Log(maxTemp / minTemp)
+	return AnnealContext(context.Background(), state, maxTemp, minTemp, steps, callback)
+}
+
+// ProgressCallback is called periodically during annealing with the
+// current step and the best energy found so far.
+type ProgressCallback func(step, steps int, energy float64)
+
+// AnnealContext is like Anneal but stops early when ctx is done, returning
+// the best state found so far.
+func AnnealContext(ctx context.Context, state Annealable, maxTemp, minTemp float64, steps int, callback AnnealCallback) Annealable {
+	state = AnnealProgress(ctx, state, maxTemp, minTemp, steps, callback, PrintProgress())
+	fmt.Println()
+	return state
+}
+
+// AnnealProgress is like AnnealContext but reports progress to progress
+// instead of printing it.
+func AnnealProgress(ctx context.Context, state Annealable, maxTemp, minTemp float64, steps int, callback AnnealCallback, progress ProgressCallback) Annealable {
+	cooling := Cooling{ScheduleExponential, maxTemp, minTemp, false, 0}
+	return AnnealCooling(ctx, state, cooling, steps, callback, progress)
+}
+
+// AnnealCooling is like AnnealProgress but follows the temperatures of
+// cooling, whose unset temperatures are filled in from DefaultCooling or
+// calibrated first.
+func AnnealCooling(ctx context.Context, state Annealable, cooling Cooling, steps int, callback AnnealCallback, progress ProgressCallback) Annealable {
 	state = state.Copy()
+	cooler := newCooler(cooling.resolve(state), steps)
 	bestState := state.Copy()
 	if callback != nil {
 		callback(bestState)
@@ -27,33 +53,52 @@ func Anneal(state Annealable, maxTemp, minTemp float64, steps int, callback Anne
 	bestEnergy := state.Energy()
 	previousEnergy := bestEnergy
 	rate := steps / 200
+	if rate < 1 {
+		rate = 1
+	}
 	for step := 0; step < steps; step++ {
-		pct := float64(step) / float64(steps-1)
-		temp := maxTemp * math.Exp(factor*pct)
-		if step%rate == 0 {
-			showProgress(step, steps, bestEnergy, time.Since(start).Seconds())
+		if step%256 == 0 && ctx.Err() != nil {
+			break
+		}
+		temp := cooler.Temperature(step)
+		if step%rate == 0 && progress != nil {
+			progress(step, steps, bestEnergy)
 		}
 		undo := state.DoMove()
 		energy := state.Energy()
 		change := energy - previousEnergy
+		accepted := true
+		improved := false
 		if change > 0 && math.Exp(-change/temp) < rand.Float64() {
 			state.UndoMove(undo)
+			accepted = false
 		} else {
 			previousEnergy = energy
 			if energy < bestEnergy {
 				bestEnergy = energy
 				bestState = state.Copy()
+				improved = true
 				if callback != nil {
 					callback(bestState)
 				}
 			}
 		}
+		cooler.Update(step, change > 0, accepted, improved)
+	}
+	if progress != nil {
+		progress(steps, steps, bestEnergy)
 	}
-	showProgress(steps, steps, bestEnergy, time.Since(start).Seconds())
-	fmt.Println()
 	return bestState
 }
 
+// printProgress returns a ProgressCallback that prints a progress bar.
+func PrintProgress() ProgressCallback {
+	start := time.Now()
+	return func(step, steps int, energy float64) {
+		showProgress(step, steps, energy, time.Since(start).Seconds())
+	}
+}
+
 func showProgress(i, n int, e, d float64) {
 	pct := int(100 * float64(i) / float64(n))
 	fmt.Printf("  %3d%% [", pct)
@@ -0,0 +1,147 @@
+package pack3d
+
+import (
+	"math"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// DefaultSlabs is the number of Z slabs used by SlabMasses if Slabs is 0.
+const DefaultSlabs = 10
+
+// meshMass returns the volume and the centroid of a closed mesh, summing
+// the tetrahedra between each triangle and the origin. Flat or open meshes
+// without volume get the center of their bounding box.
+func meshMass(mesh *fauxgl.Mesh) (float64, fauxgl.Vector) {
+	var volume float64
+	var centroid fauxgl.Vector
+	for _, t := range mesh.Triangles {
+		p1, p2, p3 := t.V1.Position, t.V2.Position, t.V3.Position
+		v := p1.Dot(p2.Cross(p3)) / 6
+		volume += v
+		centroid = centroid.Add(p1.Add(p2).Add(p3).MulScalar(v / 4))
+	}
+	if math.Abs(volume) < 1e-12 {
+		return 0, meshCenter(mesh)
+	}
+	return math.Abs(volume), centroid.DivScalar(volume)
+}
+
+// centroidsForMesh returns the centroid of the centered mesh in every
+// rotation, like treesForMesh.
+func centroidsForMesh(mesh *fauxgl.Mesh, centroid fauxgl.Vector) []fauxgl.Vector {
+	local := centroid.Sub(meshCenter(mesh))
+	result := make([]fauxgl.Vector, len(Rotations))
+	for i, r := range Rotations {
+		result[i] = r.MulPosition(local)
+	}
+	return result
+}
+
+// Centroid returns the item's center of mass in its packed position.
+func (item *Item) Centroid() fauxgl.Vector {
+	return item.Centroids[item.Rotation].Add(item.Translation)
+}
+
+// Mass returns the total mass of the items.
+func (m *Model) Mass() float64 {
+	var mass float64
+	for _, item := range m.Items {
+		mass += item.Mass
+	}
+	return mass
+}
+
+// CenterOfMass returns the center of mass of the items.
+func (m *Model) CenterOfMass() fauxgl.Vector {
+	var mass float64
+	var sum fauxgl.Vector
+	for _, item := range m.Items {
+		mass += item.Mass
+		sum = sum.Add(item.Centroid().MulScalar(item.Mass))
+	}
+	if mass == 0 {
+		return m.BoundingBox().Anchor(fauxgl.Vector{0.5, 0.5, 0.5})
+	}
+	return sum.DivScalar(mass)
+}
+
+// buildBox is the container, or the bounding box of the items if there is
+// none.
+func (m *Model) buildBox() fauxgl.Box {
+	if m.Container != fauxgl.EmptyBox {
+		return m.Container
+	}
+	return m.BoundingBox()
+}
+
+// BalanceOffset returns the horizontal distance of the center of mass from
+// the center of the build volume, relative to the size of the volume, so
+// that 0 is centered and 0.5 is at a side.
+func (m *Model) BalanceOffset() float64 {
+	box := m.buildBox()
+	size := box.Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return 0
+	}
+	d := m.CenterOfMass().Sub(box.Anchor(fauxgl.Vector{0.5, 0.5, 0.5}))
+	return math.Hypot(d.X/size.X, d.Y/size.Y)
+}
+
+// meshHeights returns the range of heights the meshes of the items may
+// fill, from the floor of the build volume to the top of the items, less
+// the padding that keeps them from both.
+func (m *Model) meshHeights() (float64, float64) {
+	return m.buildBox().Min.Z + m.Padding, m.BoundingBox().Max.Z - m.Padding
+}
+
+// SlabMasses divides the meshHeights into Slabs equal slabs and returns the
+// mass in each. Each item's mass is spread evenly over its height.
+func (m *Model) SlabMasses() []float64 {
+	n := m.Slabs
+	if n <= 0 {
+		n = DefaultSlabs
+	}
+	bottom, top := m.meshHeights()
+	height := (top - bottom) / float64(n)
+	result := make([]float64, n)
+	if height <= 0 {
+		return result
+	}
+	for _, item := range m.Items {
+		box := item.Trees[item.Rotation][0].Translate(item.Translation)
+		z0 := box.Min.Z + m.Padding
+		z1 := box.Max.Z - m.Padding
+		if z1 <= z0 {
+			continue
+		}
+		for i := range result {
+			lo := bottom + float64(i)*height
+			overlap := math.Min(z1, lo+height) - math.Max(z0, lo)
+			if overlap > 0 {
+				result[i] += item.Mass * overlap / (z1 - z0)
+			}
+		}
+	}
+	return result
+}
+
+// SlabImbalance returns the coefficient of variation of SlabMasses, 0 if
+// every slab holds the same mass.
+func (m *Model) SlabImbalance() float64 {
+	masses := m.SlabMasses()
+	var mean float64
+	for _, x := range masses {
+		mean += x
+	}
+	mean /= float64(len(masses))
+	if mean == 0 {
+		return 0
+	}
+	var variance float64
+	for _, x := range masses {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(masses))
+	return math.Sqrt(variance) / mean
+}
@@ -0,0 +1,95 @@
+package pack3d
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// testCube is a cube part placed with its minimum corner at Min.
+type testCube struct {
+	Min     fauxgl.Vector
+	Side    float64
+	Density float64
+}
+
+// cubeModel adds the cubes to m and moves each to its place. Cubes look the
+// same in every rotation, so the rotations are left as they are.
+func cubeModel(t *testing.T, m *Model, cubes ...testCube) *Model {
+	for _, c := range cubes {
+		box := fauxgl.Box{c.Min, c.Min.AddScalar(c.Side)}
+		mesh := fauxgl.NewCubeForBox(box)
+		if err := m.AddPart(Part{Mesh: mesh, Count: 1, Density: c.Density}, 2); err != nil {
+			t.Fatal(err)
+		}
+		m.Items[len(m.Items)-1].Translation = box.Anchor(fauxgl.Vector{0.5, 0.5, 0.5})
+	}
+	return m
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestSlabMasses(t *testing.T) {
+	container := fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{60, 60, 60}}
+	tests := []struct {
+		name      string
+		container fauxgl.Box
+		slabs     int
+		cubes     []testCube
+		masses    []float64
+		imbalance float64
+	}{
+		{"one cube", fauxgl.EmptyBox, 2, []testCube{{fauxgl.Vector{}, 10, 0}}, []float64{500, 500}, 0},
+		{"one cube on the floor", container, 2, []testCube{{fauxgl.Vector{2.5, 2.5, 2.5}, 10, 0}}, []float64{500, 500}, 0},
+		{"stacked cubes", fauxgl.EmptyBox, 2, []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{0, 0, 10}, 10, 0}}, []float64{1000, 1000}, 0},
+		{"heavy cube on top", fauxgl.EmptyBox, 2, []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{0, 0, 10}, 10, 3}}, []float64{1000, 3000}, 0.5},
+		{"cube in the lower slabs", container, 4, []testCube{{fauxgl.Vector{2.5, 2.5, 2.5}, 10, 0}, {fauxgl.Vector{20, 20, 2.5}, 20, 0}}, []float64{2500, 2500, 2000, 2000}, 1.0 / 9},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Container = test.container
+		m.Slabs = test.slabs
+		cubeModel(t, m, test.cubes...)
+		masses := m.SlabMasses()
+		if len(masses) != len(test.masses) {
+			t.Fatalf("%s: %d slabs, want %d", test.name, len(masses), len(test.masses))
+		}
+		for i := range masses {
+			if !closeTo(masses[i], test.masses[i]) {
+				t.Errorf("%s: slab %d holds %g, want %g", test.name, i, masses[i], test.masses[i])
+			}
+		}
+		if imbalance := m.SlabImbalance(); !closeTo(imbalance, test.imbalance) {
+			t.Errorf("%s: imbalance %g, want %g", test.name, imbalance, test.imbalance)
+		}
+	}
+}
+
+func TestCenterOfMass(t *testing.T) {
+	container := fauxgl.Box{fauxgl.Vector{-10, -10, 0}, fauxgl.Vector{40, 20, 20}}
+	tests := []struct {
+		name   string
+		cubes  []testCube
+		center fauxgl.Vector
+		offset float64
+	}{
+		{"equal masses", []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{20, 0, 0}, 10, 0}}, fauxgl.Vector{15, 5, 5}, 0},
+		{"heavy right", []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{20, 0, 0}, 10, 3}}, fauxgl.Vector{20, 5, 5}, 0.1},
+		{"one at the side", []testCube{{fauxgl.Vector{}, 10, 2}}, fauxgl.Vector{5, 5, 5}, 0.2},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Container = container
+		m.Padding = 0
+		cubeModel(t, m, test.cubes...)
+		if c := m.CenterOfMass(); c.Distance(test.center) > 1e-6 {
+			t.Errorf("%s: center of mass %v, want %v", test.name, c, test.center)
+		}
+		if offset := m.BalanceOffset(); !closeTo(offset, test.offset) {
+			t.Errorf("%s: offset %g, want %g", test.name, offset, test.offset)
+		}
+	}
+}
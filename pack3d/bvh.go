@@ -1,17 +1,25 @@
 package pack3d
 
-import "github.com/fogleman/fauxgl"
+import (
+	"math"
+	"sort"
+
+	"github.com/fogleman/fauxgl"
+)
 
 type Tree []fauxgl.Box
 
-func NewTreeForMesh(mesh *fauxgl.Mesh, depth int) Tree {
+// NewTreeForMesh builds a bounding volume hierarchy of the centered mesh.
+// Every box is grown by padding, so that two meshes whose trees do not
+// intersect are at least twice the padding apart.
+func NewTreeForMesh(mesh *fauxgl.Mesh, depth int, padding float64) Tree {
 	mesh = mesh.Copy()
 	mesh.Center()
 	boxes := make([]fauxgl.Box, len(mesh.Triangles))
 	for i, t := range mesh.Triangles {
 		boxes[i] = t.BoundingBox()
 	}
-	root := NewNode(boxes, depth)
+	root := NewNode(boxes, depth, padding)
 	tree := make(Tree, 1<<uint(depth+1)-1)
 	root.Flatten(tree, 0)
 	return tree
@@ -51,6 +59,59 @@ func (a Tree) intersects(b Tree, t1, t2 fauxgl.Vector, i, j int) bool {
 	}
 }
 
+// SweepIntersects reports whether a, moved from t1 in direction dir without
+// end, would pass through b at t2.
+func (a Tree) SweepIntersects(b Tree, t1, t2, dir fauxgl.Vector) bool {
+	return a.sweepIntersects(b, t1, t2, dir, 0, 0)
+}
+
+func (a Tree) sweepIntersects(b Tree, t1, t2, dir fauxgl.Vector, i, j int) bool {
+	if !boxesIntersect(sweepBox(a[i], dir), b[j], t1, t2) {
+		return false
+	}
+	i1 := i*2 + 1
+	i2 := i*2 + 2
+	j1 := j*2 + 1
+	j2 := j*2 + 2
+	if i1 >= len(a) && j1 >= len(b) {
+		return true
+	} else if i1 >= len(a) {
+		return a.sweepIntersects(b, t1, t2, dir, i, j1) || a.sweepIntersects(b, t1, t2, dir, i, j2)
+	} else if j1 >= len(b) {
+		return a.sweepIntersects(b, t1, t2, dir, i1, j) || a.sweepIntersects(b, t1, t2, dir, i2, j)
+	} else {
+		return a.sweepIntersects(b, t1, t2, dir, i1, j1) ||
+			a.sweepIntersects(b, t1, t2, dir, i1, j2) ||
+			a.sweepIntersects(b, t1, t2, dir, i2, j1) ||
+			a.sweepIntersects(b, t1, t2, dir, i2, j2)
+	}
+}
+
+// sweepBox extends box without end in the directions of the components of
+// dir.
+func sweepBox(box fauxgl.Box, dir fauxgl.Vector) fauxgl.Box {
+	if box == fauxgl.EmptyBox {
+		return box
+	}
+	inf := math.Inf(1)
+	if dir.X > 0 {
+		box.Max.X = inf
+	} else if dir.X < 0 {
+		box.Min.X = -inf
+	}
+	if dir.Y > 0 {
+		box.Max.Y = inf
+	} else if dir.Y < 0 {
+		box.Min.Y = -inf
+	}
+	if dir.Z > 0 {
+		box.Max.Z = inf
+	} else if dir.Z < 0 {
+		box.Min.Z = -inf
+	}
+	return box
+}
+
 func boxesIntersect(b1, b2 fauxgl.Box, t1, t2 fauxgl.Vector) bool {
 	if b1 == fauxgl.EmptyBox || b2 == fauxgl.EmptyBox {
 		return false
@@ -69,10 +130,15 @@ type Node struct {
 	Right *Node
 }
 
-func NewNode(boxes []fauxgl.Box, depth int) *Node {
-	box := fauxgl.BoxForBoxes(boxes).Offset(2.5)
+func NewNode(boxes []fauxgl.Box, depth int, padding float64) *Node {
+	if len(boxes) == 0 {
+		// padding an empty box would leave a solid box at the center of
+		// the mesh, which would keep other items out of its voids
+		return &Node{fauxgl.EmptyBox, nil, nil}
+	}
+	box := fauxgl.BoxForBoxes(boxes).Offset(padding)
 	node := &Node{box, nil, nil}
-	node.Split(boxes, depth)
+	node.Split(boxes, depth, padding)
 	return node
 }
 
@@ -86,7 +152,7 @@ func (a *Node) Flatten(tree Tree, i int) {
 	}
 }
 
-func (node *Node) Split(boxes []fauxgl.Box, depth int) {
+func (node *Node) Split(boxes []fauxgl.Box, depth int, padding float64) {
 	if depth == 0 {
 		return
 	}
@@ -126,12 +192,19 @@ func (node *Node) Split(boxes []fauxgl.Box, depth int) {
 			}
 		}
 	}
-	if bestAxis == AxisNone {
-		return
+	var l, r []fauxgl.Box
+	if bestAxis != AxisNone {
+		l, r = partition(boxes, bestAxis, bestPoint, bestSide)
 	}
-	l, r := partition(boxes, bestAxis, bestPoint, bestSide)
-	node.Left = NewNode(l, depth-1)
-	node.Right = NewNode(r, depth-1)
+	if len(l) == 0 || len(r) == 0 {
+		// a few boxes spanning the node, like the faces of a hollow box,
+		// keep every partition from splitting off anything, and without
+		// padding none may be smaller than the node. A node that is not
+		// split would leave empty boxes below it that intersect nothing.
+		l, r = medianPartition(boxes, box)
+	}
+	node.Left = NewNode(l, depth-1, padding)
+	node.Right = NewNode(r, depth-1, padding)
 }
 
 func partitionBox(box fauxgl.Box, axis Axis, point float64) (left, right bool) {
@@ -186,3 +259,26 @@ func partition(boxes []fauxgl.Box, axis Axis, point float64, side bool) (left, r
 	}
 	return
 }
+
+// medianPartition splits boxes in half by their centers along the longest
+// axis of bounds.
+func medianPartition(boxes []fauxgl.Box, bounds fauxgl.Box) (left, right []fauxgl.Box) {
+	size := bounds.Size()
+	key := func(box fauxgl.Box) float64 {
+		c := box.Anchor(fauxgl.Vector{0.5, 0.5, 0.5})
+		switch {
+		case size.X >= size.Y && size.X >= size.Z:
+			return c.X
+		case size.Y >= size.Z:
+			return c.Y
+		}
+		return c.Z
+	}
+	sorted := make([]fauxgl.Box, len(boxes))
+	copy(sorted, boxes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return key(sorted[i]) < key(sorted[j])
+	})
+	n := len(sorted) / 2
+	return sorted[:n], sorted[n:]
+}
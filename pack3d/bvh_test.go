@@ -0,0 +1,56 @@
+package pack3d
+
+import (
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+func TestTreePadding(t *testing.T) {
+	cube := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{10, 10, 10}})
+	tests := []struct {
+		name       string
+		padding    float64
+		offset     float64
+		intersects bool
+	}{
+		{"overlapping", 0, 5, true},
+		{"touching", 0, 10, true},
+		{"apart", 0, 10.5, false},
+		{"closer than the padding", 1, 11.5, true},
+		{"farther than the padding", 1, 12.5, false},
+	}
+	for _, test := range tests {
+		a := NewTreeForMesh(cube, 4, test.padding)
+		if size := a[0].Size(); !closeTo(size.X, 10+2*test.padding) {
+			t.Errorf("%s: root box is %v, want a side of %g", test.name, size, 10+2*test.padding)
+		}
+		b := a.Transform(fauxgl.Identity())
+		if got := a.Intersects(b, fauxgl.Vector{}, fauxgl.Vector{test.offset, 0, 0}); got != test.intersects {
+			t.Errorf("%s: intersects %v, want %v", test.name, got, test.intersects)
+		}
+	}
+}
+
+func TestSweepIntersects(t *testing.T) {
+	a := NewTreeForMesh(fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{10, 10, 10}}), 4, 0)
+	tests := []struct {
+		name       string
+		position   fauxgl.Vector
+		dir        fauxgl.Vector
+		intersects bool
+	}{
+		{"ahead", fauxgl.Vector{20, 0, 0}, fauxgl.Vector{1, 0, 0}, true},
+		{"behind", fauxgl.Vector{20, 0, 0}, fauxgl.Vector{-1, 0, 0}, false},
+		{"beside the path", fauxgl.Vector{20, 0, 0}, fauxgl.Vector{0, 1, 0}, false},
+		{"off to the side", fauxgl.Vector{20, 20, 0}, fauxgl.Vector{1, 0, 0}, false},
+		{"partly in the path", fauxgl.Vector{20, 5, 0}, fauxgl.Vector{1, 0, 0}, true},
+		{"below", fauxgl.Vector{0, 0, -20}, fauxgl.Vector{0, 0, -1}, true},
+		{"far ahead", fauxgl.Vector{0, 1000, 0}, fauxgl.Vector{0, 1, 0}, true},
+	}
+	for _, test := range tests {
+		if got := a.SweepIntersects(a, fauxgl.Vector{}, test.position, test.dir); got != test.intersects {
+			t.Errorf("%s: intersects %v, want %v", test.name, got, test.intersects)
+		}
+	}
+}
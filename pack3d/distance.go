@@ -0,0 +1,180 @@
+package pack3d
+
+import (
+	"math"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// TriangleDistance returns the smallest distance between two triangles and
+// a closest point on each. Intersecting triangles have distance zero.
+func TriangleDistance(t1, t2 *fauxgl.Triangle) (float64, fauxgl.Vector, fauxgl.Vector) {
+	a := [3]fauxgl.Vector{t1.V1.Position, t1.V2.Position, t1.V3.Position}
+	b := [3]fauxgl.Vector{t2.V1.Position, t2.V2.Position, t2.V3.Position}
+
+	// if an edge of one triangle crosses the other they intersect
+	for i := 0; i < 3; i++ {
+		if p, ok := segmentTriangle(a[i], a[(i+1)%3], b); ok {
+			return 0, p, p
+		}
+		if p, ok := segmentTriangle(b[i], b[(i+1)%3], a); ok {
+			return 0, p, p
+		}
+	}
+
+	// otherwise the closest points are on a pair of edges or are a vertex
+	// and its projection onto the other triangle
+	best := math.Inf(1)
+	var p1, p2 fauxgl.Vector
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			p, q := closestSegmentPoints(a[i], a[(i+1)%3], b[j], b[(j+1)%3])
+			if d := p.Distance(q); d < best {
+				best, p1, p2 = d, p, q
+			}
+		}
+		q := closestTrianglePoint(a[i], b)
+		if d := a[i].Distance(q); d < best {
+			best, p1, p2 = d, a[i], q
+		}
+		p := closestTrianglePoint(b[i], a)
+		if d := b[i].Distance(p); d < best {
+			best, p1, p2 = d, p, b[i]
+		}
+	}
+	return best, p1, p2
+}
+
+// InsideMesh reports whether p is inside the closed mesh, by counting how
+// many triangles a ray from p crosses.
+func InsideMesh(mesh *fauxgl.Mesh, p fauxgl.Vector) bool {
+	// an oblique direction makes it unlikely to hit edges exactly
+	dir := fauxgl.Vector{0.5377, 0.3138, 0.7832}
+	far := p.Add(dir.MulScalar(mesh.BoundingBox().Size().Length() * 2))
+	count := 0
+	for _, t := range mesh.Triangles {
+		if _, ok := segmentTriangle(p, far, [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position}); ok {
+			count++
+		}
+	}
+	return count%2 == 1
+}
+
+// segmentTriangle returns the point where segment pq crosses triangle t.
+func segmentTriangle(p, q fauxgl.Vector, t [3]fauxgl.Vector) (fauxgl.Vector, bool) {
+	const eps = 1e-12
+	dir := q.Sub(p)
+	e1 := t[1].Sub(t[0])
+	e2 := t[2].Sub(t[0])
+	h := dir.Cross(e2)
+	det := e1.Dot(h)
+	if math.Abs(det) < eps {
+		return fauxgl.Vector{}, false
+	}
+	f := 1 / det
+	s := p.Sub(t[0])
+	u := f * s.Dot(h)
+	if u < 0 || u > 1 {
+		return fauxgl.Vector{}, false
+	}
+	r := s.Cross(e1)
+	v := f * dir.Dot(r)
+	if v < 0 || u+v > 1 {
+		return fauxgl.Vector{}, false
+	}
+	x := f * e2.Dot(r)
+	if x < 0 || x > 1 {
+		return fauxgl.Vector{}, false
+	}
+	return p.Add(dir.MulScalar(x)), true
+}
+
+// closestTrianglePoint returns the point of triangle t closest to p.
+func closestTrianglePoint(p fauxgl.Vector, t [3]fauxgl.Vector) fauxgl.Vector {
+	a, b, c := t[0], t[1], t[2]
+	ab := b.Sub(a)
+	ac := c.Sub(a)
+	ap := p.Sub(a)
+	d1 := ab.Dot(ap)
+	d2 := ac.Dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		return a
+	}
+	bp := p.Sub(b)
+	d3 := ab.Dot(bp)
+	d4 := ac.Dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		return b
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		return a.Add(ab.MulScalar(d1 / (d1 - d3)))
+	}
+	cp := p.Sub(c)
+	d5 := ab.Dot(cp)
+	d6 := ac.Dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		return c
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		return a.Add(ac.MulScalar(d2 / (d2 - d6)))
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		return b.Add(c.Sub(b).MulScalar((d4 - d3) / ((d4 - d3) + (d5 - d6))))
+	}
+	denom := 1 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	return a.Add(ab.MulScalar(v)).Add(ac.MulScalar(w))
+}
+
+// closestSegmentPoints returns the closest points of segments p1q1 and
+// p2q2.
+func closestSegmentPoints(p1, q1, p2, q2 fauxgl.Vector) (fauxgl.Vector, fauxgl.Vector) {
+	const eps = 1e-12
+	d1 := q1.Sub(p1)
+	d2 := q2.Sub(p2)
+	r := p1.Sub(p2)
+	a := d1.Dot(d1)
+	e := d2.Dot(d2)
+	f := d2.Dot(r)
+	var s, t float64
+	if a <= eps && e <= eps {
+		return p1, p2
+	}
+	if a <= eps {
+		t = clamp(f/e, 0, 1)
+	} else {
+		c := d1.Dot(r)
+		if e <= eps {
+			s = clamp(-c/a, 0, 1)
+		} else {
+			b := d1.Dot(d2)
+			denom := a*e - b*b
+			if denom > eps {
+				s = clamp((b*f-c*e)/denom, 0, 1)
+			}
+			t = (b*s + f) / e
+			if t < 0 {
+				t = 0
+				s = clamp(-c/a, 0, 1)
+			} else if t > 1 {
+				t = 1
+				s = clamp((b-c)/a, 0, 1)
+			}
+		}
+	}
+	return p1.Add(d1.MulScalar(s)), p2.Add(d2.MulScalar(t))
+}
+
+func clamp(x, lo, hi float64) float64 {
+	if x < lo {
+		return lo
+	}
+	if x > hi {
+		return hi
+	}
+	return x
+}
@@ -0,0 +1,127 @@
+package pack3d
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// MeshHealth counts the problems that make a mesh's volume, and so the
+// MaxVolume of a Model, unreliable. Vertices are matched by exact position.
+type MeshHealth struct {
+	Triangles         int     `json:"triangles"`
+	Degenerate        int     `json:"degenerate"`         // zero area triangles
+	Duplicate         int     `json:"duplicate"`          // triangles with the same vertices as another
+	BoundaryEdges     int     `json:"boundary_edges"`     // edges of only one triangle, holes in the surface
+	NonManifoldEdges  int     `json:"non_manifold_edges"` // edges of more than two triangles
+	InconsistentEdges int     `json:"inconsistent_edges"` // edges whose two triangles wind the same way
+	SignedVolume      float64 `json:"signed_volume"`      // negative if the triangles face inward
+}
+
+type edgeKey struct {
+	A, B fauxgl.Vector
+}
+
+type edgeUse struct {
+	Count   int
+	Forward int // uses from A to B
+}
+
+// CheckMesh counts the problems of mesh. Degenerate and duplicate triangles
+// are left out of the edge checks.
+func CheckMesh(mesh *fauxgl.Mesh) MeshHealth {
+	h := MeshHealth{Triangles: len(mesh.Triangles)}
+	size := mesh.BoundingBox().Size().Length()
+	epsilon := 1e-12 * size * size
+	seen := make(map[[3]fauxgl.Vector]bool)
+	edges := make(map[edgeKey]*edgeUse)
+	for _, t := range mesh.Triangles {
+		p := [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position}
+		area := p[1].Sub(p[0]).Cross(p[2].Sub(p[0])).Length() / 2
+		if area <= epsilon {
+			h.Degenerate++
+			continue
+		}
+		key := sortedVertices(p)
+		if seen[key] {
+			h.Duplicate++
+			continue
+		}
+		seen[key] = true
+		h.SignedVolume += p[0].Dot(p[1].Cross(p[2])) / 6
+		for i := 0; i < 3; i++ {
+			a, b := p[i], p[(i+1)%3]
+			forward := true
+			if b.Less(a) {
+				a, b = b, a
+				forward = false
+			}
+			use := edges[edgeKey{a, b}]
+			if use == nil {
+				use = &edgeUse{}
+				edges[edgeKey{a, b}] = use
+			}
+			use.Count++
+			if forward {
+				use.Forward++
+			}
+		}
+	}
+	for _, use := range edges {
+		switch {
+		case use.Count == 1:
+			h.BoundaryEdges++
+		case use.Count > 2:
+			h.NonManifoldEdges++
+		case use.Forward != 1:
+			h.InconsistentEdges++
+		}
+	}
+	return h
+}
+
+func sortedVertices(p [3]fauxgl.Vector) [3]fauxgl.Vector {
+	if p[1].Less(p[0]) {
+		p[0], p[1] = p[1], p[0]
+	}
+	if p[2].Less(p[1]) {
+		p[1], p[2] = p[2], p[1]
+	}
+	if p[1].Less(p[0]) {
+		p[0], p[1] = p[1], p[0]
+	}
+	return p
+}
+
+// Watertight reports whether the surface is closed, so that its volume is
+// meaningful.
+func (h MeshHealth) Watertight() bool {
+	return h.BoundaryEdges == 0 && h.NonManifoldEdges == 0
+}
+
+func (h MeshHealth) OK() bool {
+	return len(h.Problems()) == 0
+}
+
+// Problems describes each kind of problem found.
+func (h MeshHealth) Problems() []string {
+	var result []string
+	add := func(n int, what string) {
+		if n > 0 {
+			result = append(result, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	add(h.Degenerate, "degenerate triangles")
+	add(h.Duplicate, "duplicate triangles")
+	add(h.BoundaryEdges, "open boundary edges")
+	add(h.NonManifoldEdges, "non-manifold edges")
+	add(h.InconsistentEdges, "edges with inconsistent winding")
+	if h.SignedVolume < 0 && h.Watertight() && h.InconsistentEdges == 0 {
+		result = append(result, "triangles face inward")
+	}
+	if math.IsNaN(h.SignedVolume) {
+		result = append(result, "invalid vertex positions")
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package pack3d
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+func TestCheckMesh(t *testing.T) {
+	box := func() *fauxgl.Mesh {
+		return fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{1, 2, 3}})
+	}
+	flip := func(t *fauxgl.Triangle) {
+		t.V1, t.V2 = t.V2, t.V1
+	}
+	tests := []struct {
+		name     string
+		mesh     func() *fauxgl.Mesh
+		health   MeshHealth // without the signed volume
+		volume   float64
+		problems []string
+	}{
+		{"closed", box, MeshHealth{Triangles: 12}, 6, nil},
+		{"hollow", hollowCube, MeshHealth{Triangles: 24}, 8000 - 4096, nil},
+		{"one flipped", func() *fauxgl.Mesh {
+			mesh := box()
+			flip(mesh.Triangles[0])
+			return mesh
+		}, MeshHealth{Triangles: 12, InconsistentEdges: 3}, 0, []string{"3 edges with inconsistent winding"}},
+		{"inside out", func() *fauxgl.Mesh {
+			mesh := box()
+			for _, t := range mesh.Triangles {
+				flip(t)
+			}
+			return mesh
+		}, MeshHealth{Triangles: 12}, -6, []string{"triangles face inward"}},
+		{"missing a triangle", func() *fauxgl.Mesh {
+			return fauxgl.NewTriangleMesh(box().Triangles[1:])
+		}, MeshHealth{Triangles: 11, BoundaryEdges: 3}, 0, []string{"3 open boundary edges"}},
+		{"degenerate and duplicate", func() *fauxgl.Mesh {
+			mesh := box()
+			line := fauxgl.NewTriangleForPoints(fauxgl.V(0, 0, 0), fauxgl.V(1, 1, 1), fauxgl.V(2, 2, 2))
+			mesh.Triangles = append(mesh.Triangles, mesh.Triangles[0], line)
+			return mesh
+		}, MeshHealth{Triangles: 14, Degenerate: 1, Duplicate: 1}, 6, []string{"1 degenerate triangles", "1 duplicate triangles"}},
+		{"sharing an edge", func() *fauxgl.Mesh {
+			mesh := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.V(0, 0, 0), fauxgl.V(1, 1, 1)})
+			mesh.Add(fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.V(1, 1, 0), fauxgl.V(2, 2, 1)}))
+			return mesh
+		}, MeshHealth{Triangles: 24, NonManifoldEdges: 1}, 2, []string{"1 non-manifold edges"}},
+	}
+	for _, test := range tests {
+		h := CheckMesh(test.mesh())
+		volume := h.SignedVolume
+		h.SignedVolume = 0
+		if h != test.health {
+			t.Errorf("%s: %+v, want %+v", test.name, h, test.health)
+		}
+		// a surface that is not closed has no meaningful volume
+		if test.health.Watertight() && test.health.InconsistentEdges == 0 && !closeTo(volume, test.volume) {
+			t.Errorf("%s: volume %g, want %g", test.name, volume, test.volume)
+		}
+		h.SignedVolume = volume
+		if problems := h.Problems(); !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("%s: problems %q, want %q", test.name, problems, test.problems)
+		}
+		if h.OK() != (test.problems == nil) {
+			t.Errorf("%s: OK is %v with problems %q", test.name, h.OK(), test.problems)
+		}
+	}
+}
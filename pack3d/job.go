@@ -0,0 +1,471 @@
+package pack3d
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// Job describes a packing run. Jobs are stored as JSON and relative paths
+// are resolved against Dir, the directory of the job file.
+type Job struct {
+	Parts         []JobPart `json:"parts"`
+	Clearance     *float64  `json:"clearance,omitempty"`
+	Volume        []float64 `json:"volume,omitempty"`
+	Energy        string    `json:"energy,omitempty"`
+	Nesting       string    `json:"nesting,omitempty"`
+	Placement     string    `json:"placement,omitempty"`
+	Separable     bool      `json:"separable,omitempty"`
+	Balance       float64   `json:"balance,omitempty"`
+	SlabBalance   float64   `json:"slab_balance,omitempty"`
+	Slabs         int       `json:"slabs,omitempty"`
+	ThermalWeight float64   `json:"thermal_weight,omitempty"`
+	ThermalLimit  float64   `json:"thermal_limit,omitempty"`
+	LayerHeight   float64   `json:"layer_height,omitempty"`
+	Detail        int       `json:"detail,omitempty"`
+	Iterations    int       `json:"iterations,omitempty"`
+	Attempts      int       `json:"attempts,omitempty"`
+	Schedules     []string  `json:"schedules,omitempty"`
+	Calibrate     bool      `json:"calibrate,omitempty"`
+	TimeBudget    Duration  `json:"time_budget,omitempty"`
+	Outputs       []string  `json:"outputs,omitempty"`
+	Dir           string    `json:"-"`
+
+	// Progress receives annealing progress; it is printed if nil.
+	Progress ProgressCallback `json:"-"`
+
+	// Warn, if not nil, is called for each part mesh that fails CheckMesh.
+	Warn func(path string, health MeshHealth) `json:"-"`
+
+	// Render, if not nil, draws the image embedded in .html reports.
+	Render func(*Model) image.Image `json:"-"`
+
+	// Stats describes how the best model was found. It is set by Run.
+	Stats RunStats `json:"-"`
+}
+
+type JobPart struct {
+	File        string  `json:"file"`
+	Count       int     `json:"count"`
+	Orientation string  `json:"orientation,omitempty"`
+	Density     float64 `json:"density,omitempty"`
+}
+
+const (
+	defaultDetail     = 8
+	defaultIterations = 2000000
+)
+
+var jobEnergies = map[string]Objective{
+	"volume": MinimizeVolume,
+	"height": MinimizeHeight,
+}
+
+var jobNestings = map[string]Nesting{
+	"allow":  NestingAllowed,
+	"prefer": NestingPreferred,
+	"forbid": NestingForbidden,
+}
+
+var jobPlacements = map[string]Placement{
+	"spiral":  PlaceSpiral,
+	"greedy":  PlaceGreedy,
+	"layers":  PlaceLayers,
+	"binpack": PlaceBinpack,
+}
+
+var jobOrientations = map[string]func() []int{
+	"any": func() []int { return nil },
+	"upright": func() []int {
+		up := AxisZ.Vector()
+		return RotationsMapping(up, up)
+	},
+	"vertical": func() []int {
+		up := AxisZ.Vector()
+		return append(RotationsMapping(up, up), RotationsMapping(up, up.Negate())...)
+	},
+}
+
+// Duration is a time.Duration that is written as a string like "1h30m" in
+// JSON. Numbers are read as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("expected a duration like \"10m\"")
+	}
+	x, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("expected a duration like \"10m\"")
+	}
+	*d = Duration(x)
+	return nil
+}
+
+// FieldError is a problem with one field of a job.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists every problem found in a job.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func LoadJob(path string) (*Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	job, err := ParseJob(data, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return job, nil
+}
+
+// ParseJob decodes and validates a JSON job. Relative paths are resolved
+// against dir.
+func ParseJob(data []byte, dir string) (*Job, error) {
+	job := &Job{Dir: dir}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(job); err != nil {
+		return nil, jsonError(data, err)
+	}
+	// a job is one JSON object, anything after it is a mistake
+	if offset := decoder.InputOffset(); len(bytes.TrimSpace(data[offset:])) > 0 {
+		offset += int64(len(data[offset:]) - len(bytes.TrimLeft(data[offset:], " \t\r\n")))
+		line, column := lineColumn(data, offset)
+		return nil, fmt.Errorf("line %d, column %d: unexpected data after the job", line, column)
+	}
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+var jsonIndex = regexp.MustCompile(`\.(\d+)`)
+
+func jsonError(data []byte, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// Offset is just past the character that is wrong
+		line, column := lineColumn(data, e.Offset-1)
+		return fmt.Errorf("line %d, column %d: %v", line, column, e)
+	case *json.UnmarshalTypeError:
+		field := jsonIndex.ReplaceAllString(e.Field, "[$1]")
+		return FieldError{field, fmt.Sprintf("expected %s, got %s", e.Type, e.Value)}
+	}
+	if err == io.ErrUnexpectedEOF {
+		line, column := lineColumn(data, int64(len(data)))
+		return fmt.Errorf("line %d, column %d: unexpected end of JSON input", line, column)
+	}
+	return err
+}
+
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func (job *Job) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(job.Dir, name)
+}
+
+// Validate checks every field of the job and fills in defaults.
+func (job *Job) Validate() error {
+	var errs ValidationError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{field, fmt.Sprintf(format, args...)})
+	}
+	if len(job.Parts) == 0 {
+		fail("parts", "at least one part is required")
+	}
+	for i, part := range job.Parts {
+		field := fmt.Sprintf("parts[%d]", i)
+		if part.File == "" {
+			fail(field+".file", "is required")
+		} else if _, err := os.Stat(job.path(part.File)); err != nil {
+			fail(field+".file", "%v", err)
+		}
+		if part.Count < 1 {
+			fail(field+".count", "must be at least 1")
+		}
+		if _, ok := jobOrientations[part.Orientation]; !ok && part.Orientation != "" {
+			fail(field+".orientation", "must be one of any, upright or vertical")
+		}
+		if part.Density < 0 {
+			fail(field+".density", "must not be negative")
+		}
+	}
+	if job.Clearance != nil && *job.Clearance < 0 {
+		fail("clearance", "must not be negative")
+	}
+	if job.Volume != nil {
+		if len(job.Volume) != 3 {
+			fail("volume", "must be [x, y, z]")
+		} else if job.Volume[0] <= 0 || job.Volume[1] <= 0 || job.Volume[2] <= 0 {
+			fail("volume", "must be positive")
+		}
+	}
+	if _, ok := jobEnergies[job.Energy]; !ok && job.Energy != "" {
+		fail("energy", "must be volume or height")
+	}
+	if job.Energy == "height" && job.Volume == nil {
+		fail("energy", "height requires a build volume")
+	}
+	if _, ok := jobNestings[job.Nesting]; !ok && job.Nesting != "" {
+		fail("nesting", "must be allow, prefer or forbid")
+	}
+	if _, ok := jobPlacements[job.Placement]; !ok && job.Placement != "" {
+		fail("placement", "must be spiral, greedy, layers or binpack")
+	}
+	if job.Balance < 0 {
+		fail("balance", "must not be negative")
+	}
+	if job.SlabBalance < 0 {
+		fail("slab_balance", "must not be negative")
+	}
+	if job.Slabs < 0 {
+		fail("slabs", "must not be negative")
+	}
+	if job.ThermalWeight < 0 {
+		fail("thermal_weight", "must not be negative")
+	}
+	if job.ThermalLimit < 0 || job.ThermalLimit > 1 {
+		fail("thermal_limit", "must be a fraction of the build plate between 0 and 1")
+	}
+	if job.ThermalWeight > 0 && job.ThermalLimit == 0 {
+		fail("thermal_limit", "thermal_weight requires a limit")
+	}
+	if job.LayerHeight < 0 {
+		fail("layer_height", "must not be negative")
+	}
+	if job.Detail < 0 || job.Detail > 16 {
+		fail("detail", "must be between 1 and 16")
+	}
+	if job.Iterations < 0 {
+		fail("iterations", "must not be negative")
+	}
+	if job.Attempts < 0 {
+		fail("attempts", "must not be negative")
+	}
+	for i, name := range job.Schedules {
+		if _, err := ParseSchedule(name); err != nil {
+			fail(fmt.Sprintf("schedules[%d]", i), "must be exponential, linear, log, adaptive or reheat")
+		}
+	}
+	if job.TimeBudget < 0 {
+		fail("time_budget", "must not be negative")
+	}
+	for i, output := range job.Outputs {
+		switch strings.ToLower(filepath.Ext(output)) {
+		case ".stl", ".3mf", ".json", ".csv", ".txt", ".html":
+		default:
+			fail(fmt.Sprintf("outputs[%d]", i), "must be an .stl, .3mf, .json, .csv, .txt or .html file")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if job.Detail == 0 {
+		job.Detail = defaultDetail
+	}
+	if job.Iterations == 0 {
+		job.Iterations = defaultIterations
+	}
+	return nil
+}
+
+// Model loads the parts of the job into a new model.
+func (job *Job) Model() (*Model, error) {
+	model := NewModel()
+	if job.Clearance != nil {
+		model.Padding = *job.Clearance / 2
+	}
+	if job.Volume != nil {
+		size := fauxgl.Vector{job.Volume[0], job.Volume[1], job.Volume[2]}
+		model.Container = fauxgl.Box{fauxgl.Vector{}, size}
+	}
+	model.Objective = jobEnergies[job.Energy]
+	model.Nesting = jobNestings[job.Nesting]
+	model.Placement = jobPlacements[job.Placement]
+	model.Cooling.Calibrate = job.Calibrate
+	model.Separable = job.Separable
+	model.BalanceWeight = job.Balance
+	model.SlabWeight = job.SlabBalance
+	if job.Slabs > 0 {
+		model.Slabs = job.Slabs
+	}
+	model.ThermalWeight = job.ThermalWeight
+	model.ThermalLimit = job.ThermalLimit
+	if job.LayerHeight > 0 {
+		model.LayerHeight = job.LayerHeight
+	}
+	var totalVolume float64
+	for i, part := range job.Parts {
+		mesh, err := fauxgl.LoadMesh(job.path(part.File))
+		if err != nil {
+			return nil, FieldError{fmt.Sprintf("parts[%d].file", i), err.Error()}
+		}
+		if job.Warn != nil {
+			if health := CheckMesh(mesh); !health.OK() {
+				job.Warn(part.File, health)
+			}
+		}
+		totalVolume += mesh.BoundingBox().Volume()
+		rotations := jobOrientations[part.Orientation]
+		if rotations == nil {
+			rotations = jobOrientations["any"]
+		}
+		if !model.fits(mesh, rotations()) {
+			return nil, FieldError{fmt.Sprintf("parts[%d]", i), "does not fit in the build volume in any allowed orientation"}
+		}
+		if err := model.AddPart(Part{part.File, mesh, part.Count, rotations(), part.Density}, job.Detail); err != nil {
+			return nil, FieldError{fmt.Sprintf("parts[%d]", i), err.Error()}
+		}
+	}
+	model.Deviation = math.Cbrt(totalVolume) / 32
+	if model.Placement == PlaceBinpack {
+		// the box layout needs all of the items
+		if err := model.Reset(); err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+// Run packs the job until its attempts or time budget are used up or ctx
+// is done, writing the outputs at the end of every attempt that improved on
+// the best. callback, if not nil, is called with each new best as it is
+// found. The best model is returned.
+func (job *Job) Run(ctx context.Context, callback func(*Model)) (*Model, error) {
+	model, err := job.Model()
+	if err != nil {
+		return nil, err
+	}
+	if job.TimeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(job.TimeBudget))
+		defer cancel()
+	}
+	var best *Model
+	bestEnergy := math.Inf(1)
+	start := time.Now()
+	for attempt := 0; job.Attempts == 0 || attempt < job.Attempts; attempt++ {
+		if len(job.Schedules) > 0 {
+			model.Cooling.Schedule, _ = ParseSchedule(job.Schedules[attempt%len(job.Schedules)])
+		}
+		improved := false
+		step := 0
+		attemptStart := time.Now()
+		progress := func(i, steps int, energy float64) {
+			step = i
+			if job.Progress != nil {
+				job.Progress(i, steps, energy)
+			} else {
+				showProgress(i, steps, energy, time.Since(attemptStart).Seconds())
+			}
+		}
+		record := func(state Annealable) {
+			if energy := state.Energy(); energy < bestEnergy {
+				best = state.(*Model)
+				bestEnergy = energy
+				improved = true
+				job.Stats = RunStats{attempt + 1, attempt*job.Iterations + step, time.Since(start)}
+				if callback != nil {
+					callback(best)
+				}
+			}
+		}
+		model.PackProgress(ctx, job.Iterations, record, progress)
+		if job.Progress == nil {
+			fmt.Println()
+		}
+		if improved {
+			if err := job.save(best); err != nil {
+				return best, err
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err := model.Reset(); err != nil {
+			return best, err
+		}
+	}
+	return best, nil
+}
+
+// save writes best to every output. Text and HTML files and .report.json
+// files are reports, manifests name the parts relative to the job, and
+// other files are written by Model.Save.
+func (job *Job) save(best *Model) error {
+	var render image.Image
+	for _, output := range job.Outputs {
+		path := job.path(output)
+		lower := strings.ToLower(output)
+		switch {
+		case strings.HasSuffix(lower, ".html"):
+			if render == nil && job.Render != nil {
+				render = job.Render(best)
+			}
+			fallthrough
+		case strings.HasSuffix(lower, ".txt"), strings.HasSuffix(lower, ".report.json"):
+			if err := NewReport(best, job.Stats).Save(path, render); err != nil {
+				return err
+			}
+		case strings.HasSuffix(lower, ".json"):
+			if err := best.Manifest(job.Dir, filepath.Dir(path)).Save(path); err != nil {
+				return err
+			}
+		default:
+			if err := best.Save(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
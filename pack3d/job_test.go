@@ -0,0 +1,186 @@
+package pack3d
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// jobDir returns a temporary directory holding a.stl, a 10 mm cube, and
+// hollow.stl, the hollowCube.
+func jobDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "pack3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meshes := map[string]*fauxgl.Mesh{
+		"a.stl":      fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{10, 10, 10}}),
+		"hollow.stl": hollowCube(),
+	}
+	for name, mesh := range meshes {
+		if err := mesh.SaveSTL(filepath.Join(dir, name)); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseJob(t *testing.T) {
+	dir := jobDir(t)
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name string
+		json string
+		errs []string // every line of the error, nil if the job is valid
+	}{
+		{"minimal", `{"parts": [{"file": "a.stl", "count": 2}]}`, nil},
+		{"everything", `{"parts": [{"file": "a.stl", "count": 1, "orientation": "upright", "density": 1.2}],
+			"clearance": 2, "volume": [100, 100, 100], "energy": "height", "nesting": "prefer",
+			"placement": "greedy", "schedules": ["adaptive", "reheat"], "time_budget": "10m",
+			"outputs": ["out.stl", "out.3mf", "out.json", "out.txt"]}`, nil},
+		{"no parts", `{}`, []string{"parts: at least one part is required"}},
+		{"trailing data", `{"parts": [{"file": "a.stl", "count": 1}]} x`, []string{"line 1, column 44: unexpected data after the job"}},
+		{"second object", "{\"parts\": [{\"file\": \"a.stl\", \"count\": 1}]}\n{}", []string{"line 2, column 1: unexpected data after the job"}},
+		{"syntax", "{\"parts\": [\n{\"file\": \"a.stl\",, \"count\": 1}]}", []string{"line 2, column 18: invalid character ',' looking for beginning of object key string"}},
+		{"truncated", `{"parts": [`, []string{"line 1, column 12: unexpected end of JSON input"}},
+		{"unknown field", `{"parts": [{"file": "a.stl", "count": 1}], "colour": "red"}`, []string{`json: unknown field "colour"`}},
+		{"wrong type", `{"parts": [{"file": "a.stl", "count": "two"}]}`, []string{"count: expected int, got string"}},
+		{"missing file", `{"parts": [{"file": "c.stl", "count": 1}]}`, []string{"parts[0].file: stat " + filepath.Join(dir, "c.stl")}},
+		{"several problems", `{"parts": [{"file": "a.stl", "count": 0, "orientation": "sideways"}],
+			"volume": [1, 2], "energy": "mass", "outputs": ["out.obj"]}`, []string{
+			"parts[0].count: must be at least 1",
+			"parts[0].orientation: must be one of any, upright or vertical",
+			"volume: must be [x, y, z]",
+			"energy: must be volume or height",
+			"outputs[0]: must be an .stl, .3mf, .json, .csv, .txt or .html file",
+		}},
+		{"height without a volume", `{"parts": [{"file": "a.stl", "count": 1}], "energy": "height"}`, []string{"energy: height requires a build volume"}},
+		{"thermal weight without a limit", `{"parts": [{"file": "a.stl", "count": 1}], "thermal_weight": 1}`, []string{"thermal_limit: thermal_weight requires a limit"}},
+		{"bad duration", `{"parts": [{"file": "a.stl", "count": 1}], "time_budget": "soon"}`, []string{`expected a duration like "10m"`}},
+	}
+	for _, test := range tests {
+		job, err := ParseJob([]byte(test.json), dir)
+		if test.errs == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if job.Detail != defaultDetail || job.Iterations != defaultIterations {
+				t.Errorf("%s: detail %d and iterations %d, want the defaults", test.name, job.Detail, job.Iterations)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(test.errs) {
+			t.Errorf("%s: %q, want %q", test.name, lines, test.errs)
+			continue
+		}
+		for i, line := range lines {
+			if !strings.Contains(line, test.errs[i]) {
+				t.Errorf("%s: %q, want %q", test.name, line, test.errs[i])
+			}
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		{`"1h30m"`, 90 * time.Minute},
+		{`90`, 90 * time.Second},
+		{`0.5`, 500 * time.Millisecond},
+	}
+	for _, test := range tests {
+		var d Duration
+		if err := d.UnmarshalJSON([]byte(test.json)); err != nil {
+			t.Errorf("%s: %v", test.json, err)
+		} else if time.Duration(d) != test.want {
+			t.Errorf("%s: %v, want %v", test.json, time.Duration(d), test.want)
+		}
+	}
+}
+
+func TestJobModel(t *testing.T) {
+	dir := jobDir(t)
+	defer os.RemoveAll(dir)
+	up := AxisZ.Vector()
+	tests := []struct {
+		name      string
+		json      string
+		rotations []int
+		err       string
+	}{
+		{"any orientation", `{"parts": [{"file": "a.stl", "count": 3}]}`, nil, ""},
+		{"upright", `{"parts": [{"file": "a.stl", "count": 3, "orientation": "upright"}]}`, RotationsMapping(up, up), ""},
+		// the boxes of the parts add up to more than the volume
+		{"nested", `{"parts": [{"file": "hollow.stl", "count": 1}, {"file": "a.stl", "count": 1}],
+			"volume": [22.5, 22.5, 22.5], "clearance": 2, "nesting": "prefer", "placement": "greedy"}`, nil, ""},
+		{"too big", `{"parts": [{"file": "a.stl", "count": 1}], "volume": [5, 50, 50]}`, nil, "parts[0]: does not fit in the build volume in any allowed orientation"},
+	}
+	for _, test := range tests {
+		job, err := ParseJob([]byte(test.json), dir)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		m, err := job.Model()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		count := 0
+		for _, part := range job.Parts {
+			count += part.Count
+		}
+		if len(m.Items) != count {
+			t.Errorf("%s: %d items, want %d", test.name, len(m.Items), count)
+		}
+		for i, item := range m.Items {
+			if len(item.Rotations) != len(test.rotations) {
+				t.Errorf("%s: item %d may take rotations %v, want %v", test.name, i, item.Rotations, test.rotations)
+			}
+			if !m.ValidChange(i) {
+				t.Errorf("%s: item %d is not in a valid position", test.name, i)
+			}
+		}
+	}
+}
+
+func TestManifestFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, base, dir string
+		want            string
+	}{
+		{"a.stl", "", ".", "a.stl"},
+		{"a.stl", "jobs", "jobs", "a.stl"},
+		{"a.stl", "jobs", "out", filepath.Join("..", "jobs", "a.stl")},
+		{"parts/a.stl", "jobs", filepath.Join("jobs", "out"), filepath.Join("..", "parts", "a.stl")},
+		{"a.stl", filepath.Join(wd, "jobs"), "out", filepath.Join("..", "jobs", "a.stl")},
+		{filepath.Join(wd, "a.stl"), "jobs", "out", filepath.Join(wd, "a.stl")},
+	}
+	for _, test := range tests {
+		if got := ManifestFile(test.name, test.base, test.dir); got != test.want {
+			t.Errorf("ManifestFile(%q, %q, %q) = %q, want %q", test.name, test.base, test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package pack3d
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// Manifest records where each item of a packing came from and how it was
+// placed, so that results can be inspected and rebuilt from the inputs. The
+// items of a sorted manifest are in the order they should be built.
+type Manifest struct {
+	Items []ManifestItem `json:"items"`
+	Order string         `json:"order,omitempty"` // sort keys of the build order, empty if unsorted
+}
+
+// ManifestItem places the mesh loaded from File by rotating it and then
+// translating it by Position.
+type ManifestItem struct {
+	File     string        `json:"file"`
+	Rotation [3][3]float64 `json:"rotation"`
+	Position [3]float64    `json:"position"`
+	Score    float64       `json:"score,omitempty"`
+}
+
+func NewManifestItem(file string, m fauxgl.Matrix, score float64) ManifestItem {
+	rotation := [3][3]float64{
+		{m.X00, m.X01, m.X02},
+		{m.X10, m.X11, m.X12},
+		{m.X20, m.X21, m.X22},
+	}
+	position := [3]float64{m.X03, m.X13, m.X23}
+	return ManifestItem{file, rotation, position, score}
+}
+
+func (item ManifestItem) Matrix() fauxgl.Matrix {
+	r := item.Rotation
+	p := item.Position
+	return fauxgl.Matrix{
+		r[0][0], r[0][1], r[0][2], p[0],
+		r[1][0], r[1][1], r[1][2], p[1],
+		r[2][0], r[2][1], r[2][2], p[2],
+		0, 0, 0, 1,
+	}
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (manifest *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Meshes loads and places the mesh of every item. Relative file names are
+// resolved against dir. Each file is only loaded once.
+func (manifest *Manifest) Meshes(dir string) ([]*fauxgl.Mesh, error) {
+	cache := make(map[string]*fauxgl.Mesh)
+	result := make([]*fauxgl.Mesh, len(manifest.Items))
+	for i, item := range manifest.Items {
+		path := item.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		mesh, ok := cache[path]
+		if !ok {
+			var err error
+			mesh, err = fauxgl.LoadMesh(path)
+			if err != nil {
+				return nil, err
+			}
+			cache[path] = mesh
+		}
+		mesh = mesh.Copy()
+		mesh.Transform(item.Matrix())
+		result[i] = mesh
+	}
+	return result, nil
+}
+
+// ManifestFile returns the name to record for the mesh file name in a
+// manifest saved in dir. Absolute names are kept. Relative names are
+// resolved against base and made relative to dir if possible, and absolute
+// otherwise.
+func ManifestFile(name, base, dir string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	path, err := filepath.Abs(filepath.Join(base, name))
+	if err != nil {
+		return filepath.Join(base, name)
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// Manifest describes the placement of every item for a manifest saved in
+// dir. Item names are used as the paths of their meshes, relative to base,
+// so items should be added with the path of their mesh.
+func (m *Model) Manifest(base, dir string) *Manifest {
+	manifest := &Manifest{}
+	for _, item := range m.Items {
+		file := ManifestFile(item.Name, base, dir)
+		manifest.Items = append(manifest.Items, NewManifestItem(file, item.Matrix(), 0))
+	}
+	return manifest
+}
@@ -1,8 +1,13 @@
 package pack3d
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/fogleman/fauxgl"
 )
@@ -26,6 +31,7 @@ type Undo struct {
 	Index       int
 	Rotation    int
 	Translation fauxgl.Vector
+	Exits       []int // exit directions of every item, if separable
 }
 
 type Item struct {
@@ -33,10 +39,20 @@ type Item struct {
 	Trees       []Tree
 	Rotation    int
 	Translation fauxgl.Vector
+	Center      fauxgl.Vector  // center of the mesh, the origin of Trees
+	Voids       [][]fauxgl.Box // empty space inside the mesh for each rotation, like Trees
+	Name        string
+	Rotations   []int // allowed indexes into Rotations, all if empty
+	Exit        int   // direction the item can be removed in, if separable
+	Mass        float64
+	Centroids   []fauxgl.Vector // center of mass relative to Translation for each rotation
+	Profiles    []Profile       // cross-sectional area along Z for each rotation
 }
 
+// Matrix transforms the item's mesh into its packed position.
 func (item *Item) Matrix() fauxgl.Matrix {
-	return Rotations[item.Rotation].Translate(item.Translation)
+	m := fauxgl.Translate(item.Center.Negate())
+	return Rotations[item.Rotation].Mul(m).Translate(item.Translation)
 }
 
 func (item *Item) Copy() *Item {
@@ -44,56 +60,355 @@ func (item *Item) Copy() *Item {
 	return &dup
 }
 
+func (item *Item) randomRotation() int {
+	if len(item.Rotations) == 0 {
+		return rand.Intn(len(Rotations))
+	}
+	return item.Rotations[rand.Intn(len(item.Rotations))]
+}
+
+// RotationsMapping returns the indexes of the Rotations that turn direction
+// a into direction b, e.g. the rotations that keep a part upright.
+func RotationsMapping(a, b fauxgl.Vector) []int {
+	var result []int
+	for i, m := range Rotations {
+		if m.MulDirection(a).Sub(b).Length() < 1e-9 {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// Part is a mesh to be packed Count times.
+type Part struct {
+	Name      string
+	Mesh      *fauxgl.Mesh
+	Count     int
+	Rotations []int   // allowed indexes into Rotations, all if empty
+	Density   float64 // mass per unit of volume, 1 if zero
+}
+
+type Objective int
+
+const (
+	MinimizeVolume Objective = iota // bounding box volume
+	MinimizeHeight                  // height above the container floor
+)
+
+// Nesting controls whether items may be placed inside the voids of other
+// items, like a small part inside a cup.
+type Nesting int
+
+const (
+	NestingAllowed   Nesting = iota // items may end up nested, but are not placed there
+	NestingPreferred                // small items are placed in the voids of large ones first
+	NestingForbidden                // no item may overlap another's voids, so all can be removed
+)
+
+const DefaultPadding = 2.5
+
+// voidResolution is the number of voxels along the longest side of a mesh
+// when looking for voids, and voidMinCells the size of the smallest void.
+const (
+	voidResolution = 32
+	voidMinCells   = 8
+)
+
 type Model struct {
 	Items     []*Item
 	MinVolume float64
 	MaxVolume float64
 	Deviation float64
+	Container fauxgl.Box // items must stay inside unless empty
+	Padding   float64    // space around each mesh, half the clearance
+	Objective Objective
+	Nesting   Nesting   // must be set before parts are added
+	Placement Placement // must be set before parts are added, PlaceBinpack only takes effect in Reset
+	Separable bool      // every item must be removable along an axis without disturbing the others
+
+	// Energy terms added to the objective, scaled by their weights: the
+	// BalanceOffset of the center of mass, and the SlabImbalance of the mass
+	// in Slabs horizontal slabs.
+	BalanceWeight float64
+	SlabWeight    float64
+	Slabs         int
+
+	// ThermalWeight scales an energy term for the ThermalExcess of layers
+	// LayerHeight thick whose fused area is more than ThermalLimit of the
+	// build plate.
+	ThermalWeight float64
+	ThermalLimit  float64
+	LayerHeight   float64
+
+	// Cooling is the annealing schedule of Pack. The models Pack finds
+	// record the schedule that found them, with calibrated temperatures.
+	Cooling Cooling
+
+	seed *binpackSeed // the layout of PlaceBinpack, shared by copies
 }
 
 func NewModel() *Model {
-	return &Model{nil, 0, 0, 1}
+	return &Model{nil, 0, 0, 1, fauxgl.EmptyBox, DefaultPadding, MinimizeVolume, NestingAllowed, PlaceSpiral, false, 0, 0, DefaultSlabs, 0, 0, DefaultLayerHeight, DefaultCooling, nil}
+}
+
+func (m *Model) Add(mesh *fauxgl.Mesh, detail, count int) error {
+	return m.AddPart(Part{Mesh: mesh, Count: count}, detail)
 }
 
-func (m *Model) Add(mesh *fauxgl.Mesh, detail, count int) {
-	tree := NewTreeForMesh(mesh, detail)
+// AddPart adds Count copies of the part at valid positions. It returns an
+// error if the part cannot fit in the container in any allowed rotation, or
+// if no valid position is found for a copy.
+func (m *Model) AddPart(part Part, detail int) error {
+	if !m.fits(part.Mesh, part.Rotations) {
+		return fmt.Errorf("%s does not fit in the container in any allowed rotation", part.Name)
+	}
+	volume, centroid := meshMass(part.Mesh)
+	density := part.Density
+	if density == 0 {
+		density = 1
+	}
+	item := Item{
+		Mesh:      part.Mesh,
+		Trees:     m.treesForMesh(part.Mesh, detail),
+		Center:    meshCenter(part.Mesh),
+		Voids:     m.voidsForMesh(part.Mesh),
+		Name:      part.Name,
+		Rotations: part.Rotations,
+		Mass:      volume * density,
+		Centroids: centroidsForMesh(part.Mesh, centroid),
+		Profiles:  profilesForMesh(part.Mesh),
+	}
+	for i := 0; i < part.Count; i++ {
+		if err := m.add(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fits reports whether the padded bounding box of mesh fits in the
+// container in at least one of rotations, or any rotation if empty.
+func (m *Model) fits(mesh *fauxgl.Mesh, rotations []int) bool {
+	if m.Container == fauxgl.EmptyBox {
+		return true
+	}
+	if len(rotations) == 0 {
+		rotations = allRotations()
+	}
+	box := mesh.BoundingBox()
+	container := m.Container.Size()
+	for _, r := range rotations {
+		size := box.Transform(Rotations[r]).Size().AddScalar(m.Padding * 2)
+		if size.X <= container.X && size.Y <= container.Y && size.Z <= container.Z {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAt adds copies of mesh with the given rotations (indexes into
+// Rotations) and translations instead of searching for random positions.
+// The placements are not checked for validity.
+func (m *Model) AddAt(mesh *fauxgl.Mesh, detail int, rotations []int, translations []fauxgl.Vector) {
+	trees := m.treesForMesh(mesh, detail)
+	center := meshCenter(mesh)
+	voids := m.voidsForMesh(mesh)
+	volume, centroid := meshMass(mesh)
+	centroids := centroidsForMesh(mesh, centroid)
+	profiles := profilesForMesh(mesh)
+	for i, rotation := range rotations {
+		item := Item{Mesh: mesh, Trees: trees, Rotation: rotation, Translation: translations[i], Center: center, Voids: voids, Mass: volume, Centroids: centroids, Profiles: profiles}
+		m.Items = append(m.Items, &item)
+		m.addVolume(trees)
+	}
+}
+
+func (m *Model) treesForMesh(mesh *fauxgl.Mesh, detail int) []Tree {
+	tree := NewTreeForMesh(mesh, detail, m.Padding)
 	trees := make([]Tree, len(Rotations))
 	for i, m := range Rotations {
 		trees[i] = tree.Transform(m)
 	}
-	for i := 0; i < count; i++ {
-		m.add(mesh, trees)
+	return trees
+}
+
+// voidsForMesh finds the voids of the centered mesh in every rotation, or
+// none if nesting is neither preferred nor forbidden.
+func (m *Model) voidsForMesh(mesh *fauxgl.Mesh) [][]fauxgl.Box {
+	if m.Nesting == NestingAllowed {
+		return nil
+	}
+	center := meshCenter(mesh)
+	voids := FindVoids(mesh, voidResolution, voidMinCells)
+	if len(voids) == 0 {
+		return nil
+	}
+	result := make([][]fauxgl.Box, len(Rotations))
+	for i, r := range Rotations {
+		for _, void := range voids {
+			result[i] = append(result[i], void.Box.Translate(center.Negate()).Transform(r))
+		}
 	}
+	return result
+}
+
+func meshCenter(mesh *fauxgl.Mesh) fauxgl.Vector {
+	return mesh.BoundingBox().Anchor(fauxgl.Vector{0.5, 0.5, 0.5})
 }
 
-func (m *Model) add(mesh *fauxgl.Mesh, trees []Tree) {
+// maxPlacementTries is the number of random positions add tries before
+// giving up on an item.
+const maxPlacementTries = 100000
+
+// add places a copy of item at a valid position found by the Placement
+// strategy, falling back to a random one. It returns an error, leaving the
+// item out, if no valid position is found.
+func (m *Model) add(item Item) error {
+	item.Rotation = 0
+	if len(item.Rotations) > 0 {
+		item.Rotation = item.Rotations[0]
+	}
+	item.Translation = fauxgl.Vector{}
 	index := len(m.Items)
-	item := Item{mesh, trees, 0, fauxgl.Vector{}}
 	m.Items = append(m.Items, &item)
+	if m.Nesting == NestingPreferred {
+		for i := 0; i < 256; i++ {
+			if m.nest(index) && m.ValidChange(index) {
+				m.addVolume(item.Trees)
+				return nil
+			}
+		}
+	}
+	switch m.Placement {
+	case PlaceGreedy, PlaceBinpack:
+		// items that did not fit in the box layout are placed greedily
+		if m.placeGreedy(index, item.allowedRotations()) {
+			m.addVolume(item.Trees)
+			return nil
+		}
+	case PlaceLayers:
+		if m.placeGreedy(index, item.flattestRotations()) {
+			m.addVolume(item.Trees)
+			return nil
+		}
+	}
+	item.Rotation = 0
+	if len(item.Rotations) > 0 {
+		item.Rotation = item.Rotations[0]
+	}
+	item.Translation = fauxgl.Vector{}
 	d := 1.0
-	for !m.ValidChange(index) {
-		item.Rotation = rand.Intn(len(Rotations))
-		item.Translation = fauxgl.RandomUnitVector().MulScalar(d)
-		d *= 1.2
+	for tries := 0; !m.ValidChange(index); tries++ {
+		if tries == maxPlacementTries {
+			m.Items = m.Items[:index]
+			return fmt.Errorf("no room for %s after %d tries", item.Name, tries)
+		}
+		item.Rotation = item.randomRotation()
+		if m.Container != fauxgl.EmptyBox {
+			item.Translation = m.Container.Anchor(fauxgl.Vector{rand.Float64(), rand.Float64(), rand.Float64()})
+		} else {
+			item.Translation = fauxgl.RandomUnitVector().MulScalar(d)
+			d *= 1.2
+		}
+	}
+	m.addVolume(item.Trees)
+	return nil
+}
+
+// nest moves item i to a random position inside a void of another item
+// that is large enough to hold it. It returns false if there is none.
+func (m *Model) nest(i int) bool {
+	item := m.Items[i]
+	rotation := item.randomRotation()
+	size := item.Trees[rotation][0].Size().SubScalar(m.Padding * 2)
+	var boxes []fauxgl.Box
+	for j, other := range m.Items {
+		if j == i || other.Voids == nil {
+			continue
+		}
+		for _, void := range other.Voids[other.Rotation] {
+			s := void.Size()
+			if size.X <= s.X && size.Y <= s.Y && size.Z <= s.Z {
+				boxes = append(boxes, void.Translate(other.Translation))
+			}
+		}
+	}
+	if len(boxes) == 0 {
+		return false
+	}
+	box := boxes[rand.Intn(len(boxes))]
+	// the walls around the void are padded too
+	slack := box.Size().Sub(size).SubScalar(m.Padding * 4).MulScalar(0.5).Max(fauxgl.Vector{})
+	offset := fauxgl.Vector{rand.Float64()*2 - 1, rand.Float64()*2 - 1, rand.Float64()*2 - 1}
+	item.Rotation = rotation
+	item.Translation = box.Anchor(fauxgl.Vector{0.5, 0.5, 0.5}).Add(offset.Mul(slack))
+	return true
+}
+
+// nested reports whether item a overlaps a void of item b.
+func nested(a, b *Item) bool {
+	if b.Voids == nil {
+		return false
 	}
+	box := a.Trees[a.Rotation][0].Translate(a.Translation)
+	for _, void := range b.Voids[b.Rotation] {
+		if box.Intersection(void.Translate(b.Translation)).Volume() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Model) addVolume(trees []Tree) {
 	tree := trees[0]
 	m.MinVolume = math.Max(m.MinVolume, tree[0].Volume())
 	m.MaxVolume += tree[0].Volume()
 }
 
-func (m *Model) Reset() {
+// Reset places every item again from scratch. It returns an error if one
+// could not be placed, which leaves the model without it.
+func (m *Model) Reset() error {
 	items := m.Items
+	if m.Nesting == NestingPreferred || m.Placement != PlaceSpiral {
+		// items with voids go first so that there is somewhere to nest,
+		// and large items first leave gaps the small ones can fill
+		items = append([]*Item(nil), items...)
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Trees[0][0].Volume() > items[j].Trees[0][0].Volume()
+		})
+	}
 	m.Items = nil
 	m.MinVolume = 0
 	m.MaxVolume = 0
+	if m.Placement == PlaceBinpack {
+		items = m.placeBinpack(items)
+	}
 	for _, item := range items {
-		m.add(item.Mesh, item.Trees)
+		if err := m.add(*item); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (m *Model) Pack(iterations int, callback AnnealCallback) *Model {
-	e := 0.5
-	return Anneal(m, 1e0*e, 1e-4*e, iterations, callback).(*Model)
+	return m.PackContext(context.Background(), iterations, callback)
+}
+
+// PackContext is like Pack but returns the best model found so far as soon
+// as ctx is done.
+func (m *Model) PackContext(ctx context.Context, iterations int, callback AnnealCallback) *Model {
+	model := m.PackProgress(ctx, iterations, callback, PrintProgress())
+	fmt.Println()
+	return model
+}
+
+// PackProgress is like PackContext but reports progress to progress instead
+// of printing it.
+func (m *Model) PackProgress(ctx context.Context, iterations int, callback AnnealCallback, progress ProgressCallback) *Model {
+	model := m.Copy().(*Model)
+	model.Cooling = m.Cooling.resolve(model)
+	return AnnealCooling(ctx, model, model.Cooling, iterations, callback, progress).(*Model)
 }
 
 func (m *Model) Meshes() []*fauxgl.Mesh {
@@ -114,6 +429,23 @@ func (m *Model) Mesh() *fauxgl.Mesh {
 	return result
 }
 
+// Save writes the packed model to an .stl, .3mf or .json manifest file, or
+// a .csv report of its layers, depending on the extension of path. Item
+// names in a manifest are taken as paths relative to the working directory.
+func (m *Model) Save(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".stl":
+		return m.Mesh().SaveSTL(path)
+	case ".3mf":
+		return m.Save3MF(path)
+	case ".json":
+		return m.Manifest("", filepath.Dir(path)).Save(path)
+	case ".csv":
+		return m.SaveLayers(path)
+	}
+	return fmt.Errorf("unsupported output format: %s", path)
+}
+
 func (m *Model) TreeMeshes() []*fauxgl.Mesh {
 	result := make([]*fauxgl.Mesh, len(m.Items))
 	for i, item := range m.Items {
@@ -139,6 +471,9 @@ func (m *Model) TreeMesh() *fauxgl.Mesh {
 func (m *Model) ValidChange(i int) bool {
 	item1 := m.Items[i]
 	tree1 := item1.Trees[item1.Rotation]
+	if m.Container != fauxgl.EmptyBox && !m.Container.ContainsBox(tree1[0].Translate(item1.Translation)) {
+		return false
+	}
 	for j := 0; j < len(m.Items); j++ {
 		if j == i {
 			continue
@@ -148,6 +483,12 @@ func (m *Model) ValidChange(i int) bool {
 		if tree1.Intersects(tree2, item1.Translation, item2.Translation) {
 			return false
 		}
+		if m.Nesting == NestingForbidden && (nested(item1, item2) || nested(item2, item1)) {
+			return false
+		}
+	}
+	if m.Separable && !m.separable(i) {
+		return false
 	}
 	return true
 }
@@ -166,17 +507,40 @@ func (m *Model) Volume() float64 {
 }
 
 func (m *Model) Energy() float64 {
-	return m.Volume() / m.MaxVolume
+	var energy float64
+	if m.Objective == MinimizeHeight && m.Container != fauxgl.EmptyBox {
+		size := m.Container.Size()
+		height := m.BoundingBox().Max.Z - m.Container.Min.Z
+		energy = height * size.X * size.Y / m.MaxVolume
+	} else {
+		energy = m.Volume() / m.MaxVolume
+	}
+	if m.BalanceWeight > 0 {
+		energy += m.BalanceWeight * m.BalanceOffset()
+	}
+	if m.SlabWeight > 0 {
+		energy += m.SlabWeight * m.SlabImbalance()
+	}
+	if m.ThermalWeight > 0 {
+		energy += m.ThermalWeight * m.ThermalExcess()
+	}
+	return energy
 }
 
 func (m *Model) DoMove() Undo {
 	i := rand.Intn(len(m.Items))
 	item := m.Items[i]
-	undo := Undo{i, item.Rotation, item.Translation}
+	undo := Undo{i, item.Rotation, item.Translation, nil}
+	if m.Separable {
+		undo.Exits = m.exits()
+	}
 	for {
-		if rand.Intn(4) == 0 {
+		if m.Nesting == NestingPreferred && rand.Intn(16) == 0 {
+			// jump into a void, if there is one
+			m.nest(i)
+		} else if rand.Intn(4) == 0 {
 			// rotate
-			item.Rotation = rand.Intn(len(Rotations))
+			item.Rotation = item.randomRotation()
 		} else {
 			// translate
 			offset := Axis(rand.Intn(3) + 1).Vector()
@@ -196,6 +560,9 @@ func (m *Model) UndoMove(undo Undo) {
 	item := m.Items[undo.Index]
 	item.Rotation = undo.Rotation
 	item.Translation = undo.Translation
+	for i, exit := range undo.Exits {
+		m.Items[i].Exit = exit
+	}
 }
 
 func (m *Model) Copy() Annealable {
@@ -203,5 +570,7 @@ func (m *Model) Copy() Annealable {
 	for i, item := range m.Items {
 		items[i] = item.Copy()
 	}
-	return &Model{items, m.MinVolume, m.MaxVolume, m.Deviation}
+	dup := *m
+	dup.Items = items
+	return &dup
 }
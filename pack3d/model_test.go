@@ -0,0 +1,139 @@
+package pack3d
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+func TestRotationsMapping(t *testing.T) {
+	up := AxisZ.Vector()
+	tests := []struct {
+		name string
+		a, b fauxgl.Vector
+	}{
+		{"upright", up, up},
+		{"upside down", up, up.Negate()},
+		{"on its side", up, AxisX.Vector()},
+		{"x to y", AxisX.Vector(), AxisY.Vector()},
+	}
+	for _, test := range tests {
+		indexes := RotationsMapping(test.a, test.b)
+		// four turns about b for each of the six directions
+		if len(indexes) != len(Rotations)/6 {
+			t.Errorf("%s: %d rotations, want %d", test.name, len(indexes), len(Rotations)/6)
+		}
+		seen := make(map[int]bool)
+		for _, i := range indexes {
+			if seen[i] {
+				t.Errorf("%s: rotation %d listed twice", test.name, i)
+			}
+			seen[i] = true
+			if d := Rotations[i].MulDirection(test.a).Distance(test.b); d > 1e-9 {
+				t.Errorf("%s: rotation %d turns %v into %v", test.name, i, test.a, Rotations[i].MulDirection(test.a))
+			}
+		}
+	}
+}
+
+func TestEnergy(t *testing.T) {
+	container := fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 100}}
+	tests := []struct {
+		name      string
+		objective Objective
+		container fauxgl.Box
+		cubes     []testCube
+		energy    float64
+	}{
+		{"volume of one cube", MinimizeVolume, fauxgl.EmptyBox, []testCube{{fauxgl.Vector{}, 10, 0}}, 1},
+		{"volume of two cubes apart", MinimizeVolume, fauxgl.EmptyBox, []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{10, 10, 0}, 10, 0}}, 2},
+		{"height of a cube on the floor", MinimizeHeight, container, []testCube{{fauxgl.Vector{}, 10, 0}}, 4},
+		{"height of a raised cube", MinimizeHeight, container, []testCube{{fauxgl.Vector{0, 0, 20}, 10, 0}}, 12},
+		{"height of stacked cubes", MinimizeHeight, container, []testCube{{fauxgl.Vector{}, 10, 0}, {fauxgl.Vector{0, 0, 10}, 10, 0}}, 4},
+		{"height without a container", MinimizeHeight, fauxgl.EmptyBox, []testCube{{fauxgl.Vector{}, 10, 0}}, 1},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Objective = test.objective
+		m.Container = test.container
+		m.Padding = 0
+		cubeModel(t, m, test.cubes...)
+		if energy := m.Energy(); !closeTo(energy, test.energy) {
+			t.Errorf("%s: energy %g, want %g", test.name, energy, test.energy)
+		}
+	}
+}
+
+func TestAddPartFits(t *testing.T) {
+	container := fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 10}}
+	tall := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{5, 5, 15}})
+	up := AxisZ.Vector()
+	tests := []struct {
+		name      string
+		mesh      *fauxgl.Mesh
+		rotations []int
+		count     int
+		err       string
+	}{
+		{"lying down", tall, nil, 1, ""},
+		{"upright", tall, RotationsMapping(up, up), 1, "does not fit"},
+		{"too long", fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{30, 1, 1}}), nil, 1, "does not fit"},
+		{"too many", fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{12, 12, 8}}), nil, 2, "no room"},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Container = container
+		m.Padding = 1
+		err := m.AddPart(Part{test.name, test.mesh, test.count, test.rotations, 0}, 2)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestSave3MF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pack3d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewModel()
+	a := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{4, 6, 8}})
+	b := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{1, 1, 1}, fauxgl.Vector{6, 6, 6}})
+	for _, part := range []Part{{"a", a, 2, nil, 0}, {"b", b, 1, nil, 0}} {
+		if err := m.AddPart(part, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "packed.3mf")
+	if err := m.Save3MF(path); err != nil {
+		t.Fatal(err)
+	}
+	meshes, names, err := Load3MF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := m.Meshes()
+	if len(meshes) != len(want) {
+		t.Fatalf("%d meshes, want %d", len(meshes), len(want))
+	}
+	for i, mesh := range meshes {
+		if names[i] != m.Items[i].Name {
+			t.Errorf("mesh %d is named %q, want %q", i, names[i], m.Items[i].Name)
+		}
+		got, box := mesh.BoundingBox(), want[i].BoundingBox()
+		if got.Min.Distance(box.Min) > 1e-4 || got.Max.Distance(box.Max) > 1e-4 {
+			t.Errorf("mesh %d is at %v, want %v", i, got, box)
+		}
+		if len(mesh.Triangles) != len(want[i].Triangles) {
+			t.Errorf("mesh %d has %d triangles, want %d", i, len(mesh.Triangles), len(want[i].Triangles))
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package pack3d
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+	"github.com/fogleman/pack3d/binpack"
+)
+
+// Placement is the strategy used to find a starting position for each item
+// before annealing.
+type Placement int
+
+const (
+	PlaceSpiral  Placement = iota // random directions at growing distances, or random points in the container
+	PlaceGreedy                   // lowest, then frontmost, then leftmost free corner, in the best rotation
+	PlaceLayers                   // like PlaceGreedy, but lying flat so that the items fill one layer at a time
+	PlaceBinpack                  // the boxes of all items packed at once by the binpack package
+)
+
+// placementGap separates items placed against each other, which would
+// otherwise touch and count as intersecting.
+const placementGap = 1e-3
+
+// binpackSeedTimeout limits the search for a box layout,
+// binpackSeedCells is the number of binpack units along the longest side
+// of the box the layout is packed into, and binpackSeedHashCell is the
+// cell size of the memo table on that scale.
+const (
+	binpackSeedTimeout  = time.Second
+	binpackSeedCells    = 256
+	binpackSeedHashCell = binpackSeedCells / 16
+)
+
+// allowedRotations returns the indexes into Rotations that the item may
+// use.
+func (item *Item) allowedRotations() []int {
+	if len(item.Rotations) > 0 {
+		return item.Rotations
+	}
+	return allRotations()
+}
+
+// allRotations returns the indexes of all of the Rotations.
+func allRotations() []int {
+	result := make([]int, len(Rotations))
+	for i := range result {
+		result[i] = i
+	}
+	return result
+}
+
+// flattestRotations returns the allowed rotations in which the item is
+// lowest.
+func (item *Item) flattestRotations() []int {
+	var result []int
+	best := math.Inf(1)
+	for _, r := range item.allowedRotations() {
+		height := item.Trees[r][0].Size().Z
+		if height < best-1e-9 {
+			result = nil
+			best = height
+		}
+		if height < best+1e-9 {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// footprint is the floor area that greedy placement fills before going up:
+// the container, or else a square as wide as a cube holding the boxes of
+// all items, with no top.
+func (m *Model) footprint(item *Item) fauxgl.Box {
+	if m.Container != fauxgl.EmptyBox {
+		return m.Container
+	}
+	side := math.Cbrt(m.MaxVolume + item.Trees[0][0].Volume())
+	return fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{side, side, math.Inf(1)}}
+}
+
+// placeGreedy moves item i to the lowest, then frontmost, then leftmost
+// corner next to the other items where it fits, trying the given rotations
+// and keeping the one whose top is lowest. It returns false if there is no
+// such corner.
+func (m *Model) placeGreedy(i int, rotations []int) bool {
+	item := m.Items[i]
+	bounds := m.footprint(item)
+	points := []fauxgl.Vector{bounds.Min}
+	for j, other := range m.Items {
+		if j == i {
+			continue
+		}
+		b := other.Trees[other.Rotation][0].Translate(other.Translation)
+		points = append(points,
+			fauxgl.Vector{b.Max.X + placementGap, b.Min.Y, b.Min.Z},
+			fauxgl.Vector{b.Min.X, b.Max.Y + placementGap, b.Min.Z},
+			fauxgl.Vector{b.Min.X, b.Min.Y, b.Max.Z + placementGap})
+	}
+	sort.Slice(points, func(a, b int) bool {
+		p, q := points[a], points[b]
+		if p.Z != q.Z {
+			return p.Z < q.Z
+		}
+		if p.Y != q.Y {
+			return p.Y < q.Y
+		}
+		return p.X < q.X
+	})
+	for _, p := range points {
+		bestRotation := -1
+		bestTop := math.Inf(1)
+		for _, r := range rotations {
+			box := item.Trees[r][0]
+			size := box.Size()
+			// items wider than the footprint may still go against its side
+			if p.X > bounds.Min.X && p.X+size.X > bounds.Max.X ||
+				p.Y > bounds.Min.Y && p.Y+size.Y > bounds.Max.Y ||
+				p.Z+size.Z > bounds.Max.Z {
+				continue
+			}
+			item.Rotation = r
+			item.Translation = p.Sub(box.Min)
+			if p.Z+size.Z < bestTop && m.ValidChange(i) {
+				bestRotation = r
+				bestTop = p.Z + size.Z
+			}
+		}
+		if bestRotation >= 0 {
+			item.Rotation = bestRotation
+			item.Translation = p.Sub(item.Trees[bestRotation][0].Min)
+			return true
+		}
+	}
+	return false
+}
+
+// binpackSeed is a box layout found by placeBinpack, kept so that later
+// resets of the same items do not search for it again.
+type binpackSeed struct {
+	meshes     []*fauxgl.Mesh
+	unit       float64
+	bounds     fauxgl.Box
+	placements []binpack.Placement
+}
+
+// matches reports whether the seed was laid out for these groups of items,
+// in any order.
+func (seed *binpackSeed) matches(groups map[*fauxgl.Mesh][]*Item) bool {
+	if seed == nil || len(seed.meshes) != len(groups) {
+		return false
+	}
+	for _, mesh := range seed.meshes {
+		if groups[mesh] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// layoutBinpack packs the boxes of the allowed rotations of one item of
+// each group, lowest placements first.
+func (m *Model) layoutBinpack(meshes []*fauxgl.Mesh, groups map[*fauxgl.Mesh][]*Item) *binpackSeed {
+	var total float64
+	for _, mesh := range meshes {
+		total += groups[mesh][0].Trees[0][0].Volume() * float64(len(groups[mesh]))
+	}
+	// leave some room, as guillotine cuts waste space, but no more than
+	// that, as every box that fits is filled
+	total *= 1.5
+	bounds := m.Container
+	if bounds == fauxgl.EmptyBox {
+		side := math.Cbrt(total)
+		bounds = fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{side, side, side}}
+	} else if size := bounds.Size(); total < size.X*size.Y*size.Z {
+		bounds.Max.Z = bounds.Min.Z + total/(size.X*size.Y)
+	}
+	size := bounds.Size()
+	unit := math.Max(size.X, math.Max(size.Y, size.Z)) / binpackSeedCells
+	var boxes []binpack.Item
+	for g, mesh := range meshes {
+		item := groups[mesh][0]
+		for _, r := range item.allowedRotations() {
+			s := item.Trees[r][0].Size()
+			v := binpack.Vector{
+				X: int(math.Ceil((s.X + placementGap) / unit)),
+				Y: int(math.Ceil((s.Y + placementGap) / unit)),
+				Z: int(math.Ceil((s.Z + placementGap) / unit)),
+			}
+			// filling the most volume places the large items first
+			score := float64(v.X) * float64(v.Y) * float64(v.Z)
+			boxes = append(boxes, binpack.Item{ID: g*len(Rotations) + r, Score: score, Size: v})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), binpackSeedTimeout)
+	defer cancel()
+	box := binpack.Box{Size: binpack.Vector{X: int(size.X / unit), Y: int(size.Y / unit), Z: int(size.Z / unit)}}
+	packer := binpack.NewPacker(boxes)
+	packer.Hash = binpack.NewSpatialHash(binpackSeedHashCell, binpack.DefaultCapacity)
+	result, _ := packer.PackContext(ctx, box)
+	// there may be more boxes than items, so the lowest are used
+	placements := result.Placements()
+	sort.SliceStable(placements, func(i, j int) bool {
+		return placements[i].Position.Z < placements[j].Position.Z
+	})
+	return &binpackSeed{meshes, unit, bounds, placements}
+}
+
+// placeBinpack lays out the boxes of items with the binpack package and
+// appends each item that got a place to the model. It returns the items
+// that did not fit. The layout is searched for once and reused by later
+// resets.
+func (m *Model) placeBinpack(items []*Item) []*Item {
+	// items of the same part share a mesh and are interchangeable
+	var meshes []*fauxgl.Mesh
+	groups := make(map[*fauxgl.Mesh][]*Item)
+	for _, item := range items {
+		if groups[item.Mesh] == nil {
+			meshes = append(meshes, item.Mesh)
+		}
+		groups[item.Mesh] = append(groups[item.Mesh], item)
+	}
+	if !m.seed.matches(groups) {
+		m.seed = m.layoutBinpack(meshes, groups)
+	}
+	meshes = m.seed.meshes
+	for _, placement := range m.seed.placements {
+		mesh := meshes[placement.Item.ID/len(Rotations)]
+		rotation := placement.Item.ID % len(Rotations)
+		group := groups[mesh]
+		if len(group) == 0 {
+			continue
+		}
+		item := *group[0]
+		p := placement.Position
+		corner := fauxgl.Vector{float64(p.X), float64(p.Y), float64(p.Z)}.MulScalar(m.seed.unit).Add(m.seed.bounds.Min)
+		item.Rotation = rotation
+		item.Translation = corner.Sub(item.Trees[rotation][0].Min)
+		index := len(m.Items)
+		m.Items = append(m.Items, &item)
+		if !m.ValidChange(index) {
+			m.Items = m.Items[:index]
+			continue
+		}
+		m.addVolume(item.Trees)
+		groups[mesh] = group[1:]
+	}
+	var rest []*Item
+	for _, mesh := range meshes {
+		rest = append(rest, groups[mesh]...)
+	}
+	return rest
+}
@@ -0,0 +1,227 @@
+package pack3d
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// RunStats records how a packing was found.
+type RunStats struct {
+	Attempt    int           // attempt that found it, from 1
+	Iterations int           // annealing iterations of all attempts until it was found, to the nearest progress report
+	Elapsed    time.Duration // time until it was found
+}
+
+// Report describes the quality of a packing and where each item went.
+type Report struct {
+	Items          int          `json:"items"`
+	Size           [3]float64   `json:"size"`                  // of the bounding box of the meshes
+	BoundingVolume float64      `json:"bounding_volume"`       // of the bounding box of the meshes
+	MeshVolume     float64      `json:"mesh_volume"`           // sum of the volumes of the meshes
+	Density        float64      `json:"density"`               // mesh volume / bounding volume
+	Utilization    float64      `json:"utilization,omitempty"` // mesh volume / container volume
+	Energy         float64      `json:"energy"`
+	RotationsUsed  int          `json:"rotations_used"` // distinct rotations among the items
+	CenterOfMass   [3]float64   `json:"center_of_mass"`
+	Schedule       string       `json:"schedule"` // cooling schedule that found the packing
+	Attempt        int          `json:"attempt,omitempty"`
+	Iterations     int          `json:"iterations,omitempty"`
+	Elapsed        Duration     `json:"time_to_solution,omitempty"`
+	Placements     []ReportItem `json:"placements"`
+}
+
+// ReportItem is the placement of one item: the indexes into Rotations and
+// the position of its center, and its bounds once placed.
+type ReportItem struct {
+	Name     string     `json:"name"`
+	Rotation int        `json:"rotation"`
+	Position [3]float64 `json:"position"`
+	Min      [3]float64 `json:"min"`
+	Max      [3]float64 `json:"max"`
+}
+
+// NewReport measures the packing of model, which was found as described by
+// stats.
+func NewReport(model *Model, stats RunStats) *Report {
+	r := &Report{}
+	r.Items = len(model.Items)
+	r.Energy = model.Energy()
+	r.Schedule = model.Cooling.String()
+	r.Attempt = stats.Attempt
+	r.Iterations = stats.Iterations
+	r.Elapsed = Duration(stats.Elapsed)
+	c := model.CenterOfMass()
+	r.CenterOfMass = [3]float64{c.X, c.Y, c.Z}
+
+	volumes := make(map[*fauxgl.Mesh]float64)
+	rotations := make(map[int]bool)
+	meshes := model.Meshes()
+	bounds := fauxgl.EmptyBox
+	for i, item := range model.Items {
+		volume, ok := volumes[item.Mesh]
+		if !ok {
+			volume = item.Mesh.Volume()
+			volumes[item.Mesh] = volume
+		}
+		r.MeshVolume += volume
+		rotations[item.Rotation] = true
+		box := meshes[i].BoundingBox()
+		bounds = bounds.Extend(box)
+		t := item.Translation
+		r.Placements = append(r.Placements, ReportItem{
+			item.Name, item.Rotation, [3]float64{t.X, t.Y, t.Z},
+			[3]float64{box.Min.X, box.Min.Y, box.Min.Z},
+			[3]float64{box.Max.X, box.Max.Y, box.Max.Z},
+		})
+	}
+	r.RotationsUsed = len(rotations)
+	size := bounds.Size()
+	r.Size = [3]float64{size.X, size.Y, size.Z}
+	r.BoundingVolume = size.X * size.Y * size.Z
+	if r.BoundingVolume > 0 {
+		r.Density = r.MeshVolume / r.BoundingVolume
+	}
+	if v := model.Container.Volume(); v > 0 {
+		r.Utilization = r.MeshVolume / v
+	}
+	return r
+}
+
+// Save writes the report as .txt, .json or .html depending on the
+// extension of path. render, if not nil, is embedded in HTML reports.
+func (r *Report) Save(path string, render image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt":
+		err = r.WriteText(file)
+	case ".json":
+		err = r.WriteJSON(file)
+	case ".html":
+		err = r.WriteHTML(file, render)
+	default:
+		err = fmt.Errorf("unsupported report format: %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, line := range r.summary() {
+		fmt.Fprintf(tw, "%s:\t%s\n", line[0], line[1])
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "item\tname\trotation\tposition\tmin\tmax")
+	for i, p := range r.Placements {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", i, p.Name, p.Rotation,
+			formatTriple(p.Position), formatTriple(p.Min), formatTriple(p.Max))
+	}
+	return tw.Flush()
+}
+
+// summary returns the label and value of every measure, for the text and
+// HTML reports.
+func (r *Report) summary() [][2]string {
+	result := [][2]string{
+		{"items", fmt.Sprint(r.Items)},
+		{"size", fmt.Sprintf("%.2f x %.2f x %.2f", r.Size[0], r.Size[1], r.Size[2])},
+		{"bounding volume", fmt.Sprintf("%.2f", r.BoundingVolume)},
+		{"mesh volume", fmt.Sprintf("%.2f", r.MeshVolume)},
+		{"density", fmt.Sprintf("%.1f%%", r.Density*100)},
+	}
+	if r.Utilization > 0 {
+		result = append(result, [2]string{"utilization", fmt.Sprintf("%.1f%%", r.Utilization*100)})
+	}
+	result = append(result,
+		[2]string{"energy", fmt.Sprintf("%.6f", r.Energy)},
+		[2]string{"rotations used", fmt.Sprint(r.RotationsUsed)},
+		[2]string{"center of mass", formatTriple(r.CenterOfMass)},
+		[2]string{"schedule", r.Schedule},
+	)
+	if r.Attempt > 0 {
+		result = append(result,
+			[2]string{"attempt", fmt.Sprint(r.Attempt)},
+			[2]string{"iterations", fmt.Sprint(r.Iterations)},
+			[2]string{"time to solution", time.Duration(r.Elapsed).Round(time.Millisecond).String()},
+		)
+	}
+	return result
+}
+
+func formatTriple(v [3]float64) string {
+	return fmt.Sprintf("%.2f, %.2f, %.2f", v[0], v[1], v[2])
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"triple": formatTriple,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pack3d report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #2A2C2B; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.25em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<h1>pack3d report</h1>
+{{if .Image}}<img src="{{.Image}}" alt="render">{{end}}
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>item</th><th>name</th><th>rotation</th><th>position</th><th>min</th><th>max</th></tr>
+{{range $i, $p := .Report.Placements}}<tr><td>{{$i}}</td><td>{{$p.Name}}</td><td>{{$p.Rotation}}</td><td>{{triple $p.Position}}</td><td>{{triple $p.Min}}</td><td>{{triple $p.Max}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes a page with the report and render, if not nil, embedded
+// as a PNG.
+func (r *Report) WriteHTML(w io.Writer, render image.Image) error {
+	var src template.URL
+	if render != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, render); err != nil {
+			return err
+		}
+		src = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+	return reportTemplate.Execute(w, struct {
+		Report  *Report
+		Summary [][2]string
+		Image   template.URL
+	}{r, r.summary(), src})
+}
@@ -0,0 +1,196 @@
+package pack3d
+
+import (
+	"fmt"
+	"math"
+)
+
+// Schedule is how the temperature falls during annealing.
+type Schedule int
+
+const (
+	ScheduleExponential Schedule = iota // geometric from MaxTemp to MinTemp
+	ScheduleLinear                      // straight from MaxTemp to MinTemp
+	ScheduleLogarithmic                 // MaxTemp / (1 + a log(1 + step)), fast at first and then slow, reaching MinTemp
+	ScheduleAdaptive                    // raised or lowered to keep the acceptance of uphill moves near a falling target
+	ScheduleReheat                      // exponential, but warmed up again whenever no new best is found for Stall steps
+)
+
+var scheduleNames = []string{"exponential", "linear", "log", "adaptive", "reheat"}
+
+func (s Schedule) String() string {
+	if s >= 0 && int(s) < len(scheduleNames) {
+		return scheduleNames[s]
+	}
+	return fmt.Sprintf("Schedule(%d)", int(s))
+}
+
+// ParseSchedule returns the schedule named by Schedule.String.
+func ParseSchedule(name string) (Schedule, error) {
+	for i, s := range scheduleNames {
+		if s == name {
+			return Schedule(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown schedule: %s", name)
+}
+
+// Cooling describes the temperatures of an annealing run.
+type Cooling struct {
+	Schedule  Schedule
+	MaxTemp   float64 // starting temperature
+	MinTemp   float64 // final temperature
+	Calibrate bool    // replace MaxTemp with CalibrateTemperature, keeping the ratio to MinTemp
+	Stall     int     // steps without a new best before ScheduleReheat warms up, steps/20 if 0
+}
+
+// DefaultCooling is the schedule Model.Pack has always used.
+var DefaultCooling = Cooling{ScheduleExponential, 0.5, 0.5e-4, false, 0}
+
+func (c Cooling) String() string {
+	return fmt.Sprintf("%s from %.3g to %.3g", c.Schedule, c.MaxTemp, c.MinTemp)
+}
+
+const (
+	// calibrationSamples moves are tried to calibrate the temperature so
+	// that calibrationAcceptance of the uphill moves are accepted at first.
+	calibrationSamples    = 200
+	calibrationAcceptance = 0.8
+
+	// adaptiveWindow is the number of steps between adjustments of
+	// ScheduleAdaptive, whose target acceptance falls from
+	// adaptiveStartRate to adaptiveEndRate.
+	adaptiveWindow    = 100
+	adaptiveStartRate = 0.5
+	adaptiveEndRate   = 1e-3
+	adaptiveFactor    = 1.1
+)
+
+// CalibrateTemperature tries samples moves on a copy of state and returns
+// the temperature at which the average uphill move is accepted with
+// probability acceptance, or 0 if no move was uphill.
+func CalibrateTemperature(state Annealable, samples int, acceptance float64) float64 {
+	state = state.Copy()
+	energy := state.Energy()
+	var sum float64
+	var n int
+	for i := 0; i < samples; i++ {
+		undo := state.DoMove()
+		if change := state.Energy() - energy; change > 0 {
+			sum += change
+			n++
+		}
+		state.UndoMove(undo)
+	}
+	if n == 0 {
+		return 0
+	}
+	return -sum / float64(n) / math.Log(acceptance)
+}
+
+// resolve fills in the temperatures that are not set, calibrating them on
+// state if asked to.
+func (c Cooling) resolve(state Annealable) Cooling {
+	ratio := DefaultCooling.MinTemp / DefaultCooling.MaxTemp
+	if c.MaxTemp > 0 && c.MinTemp > 0 {
+		ratio = c.MinTemp / c.MaxTemp
+	}
+	if c.Calibrate {
+		if t := CalibrateTemperature(state, calibrationSamples, calibrationAcceptance); t > 0 {
+			c.MaxTemp = t
+			c.MinTemp = t * ratio
+		}
+		c.Calibrate = false
+	}
+	if c.MaxTemp <= 0 {
+		c.MaxTemp = DefaultCooling.MaxTemp
+	}
+	if c.MinTemp <= 0 {
+		c.MinTemp = c.MaxTemp * ratio
+	}
+	return c
+}
+
+// cooler tracks the temperature of one annealing run.
+type cooler struct {
+	Cooling
+	steps int
+	temp  float64
+
+	// ScheduleAdaptive
+	uphill, accepted int
+
+	// ScheduleReheat: cooling restarts from startTemp at step start
+	start       int
+	startTemp   float64
+	lastImprove int
+}
+
+func newCooler(cooling Cooling, steps int) *cooler {
+	c := &cooler{Cooling: cooling, steps: steps, temp: cooling.MaxTemp, startTemp: cooling.MaxTemp}
+	if c.Stall <= 0 {
+		c.Stall = steps / 20
+		if c.Stall < 1 {
+			c.Stall = 1
+		}
+	}
+	return c
+}
+
+// Temperature returns the temperature for the given step.
+func (c *cooler) Temperature(step int) float64 {
+	if c.steps < 2 {
+		return c.temp
+	}
+	pct := float64(step) / float64(c.steps-1)
+	switch c.Schedule {
+	case ScheduleLinear:
+		c.temp = c.MaxTemp + (c.MinTemp-c.MaxTemp)*pct
+	case ScheduleLogarithmic:
+		a := (c.MaxTemp/c.MinTemp - 1) / math.Log(float64(c.steps))
+		c.temp = c.MaxTemp / (1 + a*math.Log(float64(step+1)))
+	case ScheduleAdaptive:
+		// adjusted in Update
+	case ScheduleReheat:
+		pct = float64(step-c.start) / float64(c.steps-1-c.start)
+		c.temp = c.startTemp * math.Exp(-math.Log(c.startTemp/c.MinTemp)*pct)
+	default:
+		c.temp = c.MaxTemp * math.Exp(-math.Log(c.MaxTemp/c.MinTemp)*pct)
+	}
+	return c.temp
+}
+
+// Update records the outcome of the move made at step: whether it was
+// uphill, whether it was accepted and whether it found a new best.
+func (c *cooler) Update(step int, uphill, accepted, improved bool) {
+	switch c.Schedule {
+	case ScheduleAdaptive:
+		if uphill {
+			c.uphill++
+			if accepted {
+				c.accepted++
+			}
+		}
+		if (step+1)%adaptiveWindow != 0 || c.uphill == 0 {
+			return
+		}
+		pct := float64(step) / float64(c.steps-1)
+		target := adaptiveStartRate * math.Pow(adaptiveEndRate/adaptiveStartRate, pct)
+		if float64(c.accepted)/float64(c.uphill) > target {
+			c.temp = math.Max(c.temp/adaptiveFactor, c.MinTemp)
+		} else {
+			c.temp = math.Min(c.temp*adaptiveFactor, c.MaxTemp)
+		}
+		c.uphill = 0
+		c.accepted = 0
+	case ScheduleReheat:
+		if improved {
+			c.lastImprove = step
+		} else if step-c.lastImprove >= c.Stall && step < c.steps-1 {
+			// halfway back to the starting temperature, in log space
+			c.startTemp = math.Sqrt(c.temp * c.MaxTemp)
+			c.start = step
+			c.lastImprove = step
+		}
+	}
+}
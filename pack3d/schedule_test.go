@@ -0,0 +1,146 @@
+package pack3d
+
+import (
+	"math"
+	"testing"
+)
+
+// stepState is an Annealable whose moves change its energy by each of
+// Steps in turn.
+type stepState struct {
+	energy float64
+	next   int
+	Steps  []float64
+}
+
+func (s *stepState) Energy() float64 {
+	return s.energy
+}
+
+func (s *stepState) DoMove() Undo {
+	change := s.Steps[s.next%len(s.Steps)]
+	s.next++
+	s.energy += change
+	return Undo{Index: s.next - 1}
+}
+
+func (s *stepState) UndoMove(undo Undo) {
+	s.energy -= s.Steps[undo.Index%len(s.Steps)]
+}
+
+func (s *stepState) Copy() Annealable {
+	c := *s
+	return &c
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, s := range []Schedule{ScheduleExponential, ScheduleLinear, ScheduleLogarithmic, ScheduleAdaptive, ScheduleReheat} {
+		if got, err := ParseSchedule(s.String()); err != nil || got != s {
+			t.Errorf("ParseSchedule(%q) = %v, %v", s.String(), got, err)
+		}
+	}
+	if _, err := ParseSchedule("quench"); err == nil {
+		t.Error("no error for an unknown schedule")
+	}
+}
+
+func TestCoolerTemperature(t *testing.T) {
+	a := 99 / math.Log(101)
+	tests := []struct {
+		schedule Schedule
+		middle   float64 // temperature at step 50
+	}{
+		{ScheduleExponential, 0.1},
+		{ScheduleLinear, 0.505},
+		{ScheduleLogarithmic, 1 / (1 + a*math.Log(51))},
+		{ScheduleReheat, 0.1},
+	}
+	for _, test := range tests {
+		c := newCooler(Cooling{test.schedule, 1, 0.01, false, 1000}, 101)
+		if temp := c.Temperature(0); !closeTo(temp, 1) {
+			t.Errorf("%v: starts at %g, want 1", test.schedule, temp)
+		}
+		if temp := c.Temperature(50); !closeTo(temp, test.middle) {
+			t.Errorf("%v: %g halfway, want %g", test.schedule, temp, test.middle)
+		}
+		if temp := c.Temperature(100); !closeTo(temp, 0.01) {
+			t.Errorf("%v: ends at %g, want 0.01", test.schedule, temp)
+		}
+		for step := 1; step <= 100; step++ {
+			if c.Temperature(step) >= c.Temperature(step-1) {
+				t.Errorf("%v: warmer at step %d", test.schedule, step)
+				break
+			}
+		}
+	}
+}
+
+func TestCoolerAdaptive(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted bool
+		temp     float64 // after 10 windows
+	}{
+		// more than the target rate is accepted, so it cools down to
+		// MinTemp, or less and it stays at MaxTemp
+		{"accepting", true, 0.5},
+		{"rejecting", false, 1},
+	}
+	for _, test := range tests {
+		c := newCooler(Cooling{ScheduleAdaptive, 1, 0.5, false, 0}, 100*adaptiveWindow)
+		for step := 0; step < 10*adaptiveWindow; step++ {
+			c.Temperature(step)
+			c.Update(step, true, test.accepted, false)
+		}
+		if temp := c.Temperature(10 * adaptiveWindow); !closeTo(temp, test.temp) {
+			t.Errorf("%s: temperature %g, want %g", test.name, temp, test.temp)
+		}
+	}
+}
+
+func TestCoolerReheat(t *testing.T) {
+	c := newCooler(Cooling{ScheduleReheat, 1, 0.01, false, 10}, 101)
+	var temp float64
+	// the last new best is at step 41, so it stalls at step 51
+	for step := 0; step <= 51; step++ {
+		temp = c.Temperature(step)
+		c.Update(step, false, false, step == 41)
+	}
+	// halfway back to the start in log space, and cooling from there
+	if got := c.Temperature(52); got <= temp || got >= math.Sqrt(temp) {
+		t.Errorf("reheated from %g to %g, want just under %g", temp, got, math.Sqrt(temp))
+	}
+	if got := c.Temperature(100); !closeTo(got, 0.01) {
+		t.Errorf("ends at %g, want 0.01", got)
+	}
+}
+
+func TestCalibrateTemperature(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []float64
+		temp  float64
+	}{
+		{"uphill and down", []float64{1, 3, -2}, -2 / math.Log(0.8)},
+		{"only downhill", []float64{-1, 0}, 0},
+	}
+	for _, test := range tests {
+		state := &stepState{Steps: test.steps}
+		if temp := CalibrateTemperature(state, 30, 0.8); !closeTo(temp, test.temp) {
+			t.Errorf("%s: temperature %g, want %g", test.name, temp, test.temp)
+		}
+		if state.next != 0 || state.energy != 0 {
+			t.Errorf("%s: the state was changed", test.name)
+		}
+	}
+
+	cooling := Cooling{ScheduleExponential, 2, 0.02, true, 0}.resolve(&stepState{Steps: []float64{1, 3, -2}})
+	want := -2 / math.Log(calibrationAcceptance)
+	if cooling.Calibrate || !closeTo(cooling.MaxTemp, want) || !closeTo(cooling.MinTemp, want/100) {
+		t.Errorf("calibrated to %v, want from %g to %g", cooling, want, want/100)
+	}
+	cooling = Cooling{}.resolve(nil)
+	if cooling.MaxTemp != DefaultCooling.MaxTemp || !closeTo(cooling.MinTemp, DefaultCooling.MinTemp) {
+		t.Errorf("unset temperatures resolved to %v, want %v", cooling, DefaultCooling)
+	}
+}
@@ -0,0 +1,72 @@
+package pack3d
+
+import "github.com/fogleman/fauxgl"
+
+// exitDirections are the directions in which items may be pulled out of a
+// separable packing.
+var exitDirections = []fauxgl.Vector{
+	{0, 0, 1}, {0, 0, -1},
+	{1, 0, 0}, {-1, 0, 0},
+	{0, 1, 0}, {0, -1, 0},
+}
+
+// removable reports whether item i can be pulled out along exit direction d
+// without passing through any other item.
+func (m *Model) removable(i, d int) bool {
+	item := m.Items[i]
+	tree := item.Trees[item.Rotation]
+	for j, other := range m.Items {
+		if j == i {
+			continue
+		}
+		if tree.SweepIntersects(other.Trees[other.Rotation], item.Translation, other.Translation, exitDirections[d]) {
+			return false
+		}
+	}
+	return true
+}
+
+// findExit returns an exit direction along which item i can be removed,
+// trying its last one first, or -1 if it is locked in.
+func (m *Model) findExit(i int) int {
+	exit := m.Items[i].Exit
+	if m.removable(i, exit) {
+		return exit
+	}
+	for d := range exitDirections {
+		if d != exit && m.removable(i, d) {
+			return d
+		}
+	}
+	return -1
+}
+
+// separable reports whether every item can still be removed after item i
+// was moved, and records the exit of each if so. Every other item could be
+// removed before the move, so only those whose exit item i now blocks need
+// a new one.
+func (m *Model) separable(i int) bool {
+	exits := make([]int, len(m.Items))
+	moved := m.Items[i]
+	for j, item := range m.Items {
+		exits[j] = item.Exit
+		if j == i || item.Trees[item.Rotation].SweepIntersects(
+			moved.Trees[moved.Rotation], item.Translation, moved.Translation, exitDirections[item.Exit]) {
+			if exits[j] = m.findExit(j); exits[j] < 0 {
+				return false
+			}
+		}
+	}
+	for j, item := range m.Items {
+		item.Exit = exits[j]
+	}
+	return true
+}
+
+func (m *Model) exits() []int {
+	result := make([]int, len(m.Items))
+	for i, item := range m.Items {
+		result[i] = item.Exit
+	}
+	return result
+}
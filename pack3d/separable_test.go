@@ -0,0 +1,51 @@
+package pack3d
+
+import (
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+func TestSeparable(t *testing.T) {
+	cube := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{10, 10, 10}})
+	// the six neighbours of a cube at the origin, one on each side
+	sides := []fauxgl.Vector{{0, 0, 12}, {0, 0, -12}, {12, 0, 0}, {-12, 0, 0}, {0, 12, 0}, {0, -12, 0}}
+	tests := []struct {
+		name       string
+		neighbours []fauxgl.Vector
+		exit       int // exit of the middle cube, -1 if it is locked in
+	}{
+		{"alone", nil, 0},
+		{"on top", sides[:1], 1},
+		{"boxed in", sides, -1},
+		{"open at one side", append(append([]fauxgl.Vector(nil), sides[:4]...), sides[5]), 4},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Padding = 0
+		m.Separable = true
+		m.AddAt(cube, 4, make([]int, len(test.neighbours)+1), append([]fauxgl.Vector{{}}, test.neighbours...))
+		separable := m.separable(0)
+		if separable != (test.exit >= 0) {
+			t.Errorf("%s: separable %v", test.name, separable)
+			continue
+		}
+		if separable && m.Items[0].Exit != test.exit {
+			t.Errorf("%s: exit %v, want %v", test.name, exitDirections[m.Items[0].Exit], exitDirections[test.exit])
+		}
+	}
+
+	// an item in the cavity of another cannot get out
+	m := NewModel()
+	m.Padding = 0
+	m.Separable = true
+	m.AddAt(hollowCube(), 4, []int{0}, []fauxgl.Vector{{}})
+	m.AddAt(fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{5, 5, 5}}), 4, []int{0}, []fauxgl.Vector{{}})
+	if m.separable(1) {
+		t.Error("an item in a cavity is separable")
+	}
+	m.Items[1].Translation = fauxgl.Vector{40, 0, 0}
+	if !m.separable(1) {
+		t.Error("an item beside a hollow one is not separable")
+	}
+}
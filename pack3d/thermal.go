@@ -0,0 +1,227 @@
+package pack3d
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// DefaultLayerHeight is the thickness of the layers of LayerAreas if
+// LayerHeight is 0.
+const DefaultLayerHeight = 1.0
+
+// profileSamples is the number of slices of a mesh's area profile.
+const profileSamples = 64
+
+// Profile is the cross-sectional area of a mesh along its height, sampled at
+// the centers of equal slices between Min and Max, which are relative to
+// the item's Translation.
+type Profile struct {
+	Min, Max float64
+	Areas    []float64
+}
+
+// At returns the cross-sectional area at height z relative to the item's
+// Translation.
+func (p Profile) At(z float64) float64 {
+	if z < p.Min || z >= p.Max || len(p.Areas) == 0 {
+		return 0
+	}
+	i := int((z - p.Min) / (p.Max - p.Min) * float64(len(p.Areas)))
+	if i >= len(p.Areas) {
+		i = len(p.Areas) - 1
+	}
+	return p.Areas[i]
+}
+
+// profilesForMesh slices the centered mesh along each axis and returns its
+// Profile in every rotation, like treesForMesh.
+func profilesForMesh(mesh *fauxgl.Mesh) []Profile {
+	center := meshCenter(mesh)
+	box := mesh.BoundingBox()
+	var axes [3]Profile
+	for a := 0; a < 3; a++ {
+		lo, hi := axisValue(box.Min, a), axisValue(box.Max, a)
+		axes[a] = Profile{lo - axisValue(center, a), hi - axisValue(center, a), sliceAreas(mesh, a, lo, hi, profileSamples)}
+	}
+	result := make([]Profile, len(Rotations))
+	for i, r := range Rotations {
+		// find the axis of the mesh that the rotation turns up or down
+		for a := 0; a < 3; a++ {
+			up := r.MulPosition(Axis(a + 1).Vector()).Z
+			if math.Abs(up) < 0.5 {
+				continue
+			}
+			p := axes[a]
+			if up < 0 {
+				areas := make([]float64, len(p.Areas))
+				for j, x := range p.Areas {
+					areas[len(areas)-1-j] = x
+				}
+				p = Profile{-p.Max, -p.Min, areas}
+			}
+			result[i] = p
+		}
+	}
+	return result
+}
+
+func axisValue(v fauxgl.Vector, a int) float64 {
+	switch a {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// sliceAreas returns the cross-sectional areas of a closed mesh at the
+// centers of n equal slices from lo to hi along axis a. Each triangle that
+// crosses a plane adds a segment of the section's outline, and the area of
+// the outline is summed from the segments without joining them up.
+func sliceAreas(mesh *fauxgl.Mesh, a int, lo, hi float64, n int) []float64 {
+	areas := make([]float64, n)
+	step := (hi - lo) / float64(n)
+	if step <= 0 {
+		return areas
+	}
+	// u and v are the other two axes, in right handed order
+	u, v := (a+1)%3, (a+2)%3
+	for _, t := range mesh.Triangles {
+		p := [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position}
+		normal := p[1].Sub(p[0]).Cross(p[2].Sub(p[0]))
+		// the outline runs counterclockwise seen from above, with the
+		// outside of the mesh to its right
+		du, dv := -axisValue(normal, v), axisValue(normal, u)
+		w0, w1, w2 := axisValue(p[0], a), axisValue(p[1], a), axisValue(p[2], a)
+		first := int(math.Ceil((math.Min(w0, math.Min(w1, w2))-lo)/step - 0.5))
+		last := int(math.Floor((math.Max(w0, math.Max(w1, w2))-lo)/step - 0.5))
+		if first < 0 {
+			first = 0
+		}
+		if last >= n {
+			last = n - 1
+		}
+		for i := first; i <= last; i++ {
+			w := lo + (float64(i)+0.5)*step
+			var points [][2]float64
+			for j := 0; j < 3; j++ {
+				a1, a2 := axisValue(p[j], a)-w, axisValue(p[(j+1)%3], a)-w
+				if (a1 >= 0) == (a2 >= 0) {
+					continue
+				}
+				s := a1 / (a1 - a2)
+				q := p[j].Add(p[(j+1)%3].Sub(p[j]).MulScalar(s))
+				points = append(points, [2]float64{axisValue(q, u), axisValue(q, v)})
+			}
+			if len(points) != 2 {
+				continue
+			}
+			p1, p2 := points[0], points[1]
+			if (p2[0]-p1[0])*du+(p2[1]-p1[1])*dv < 0 {
+				p1, p2 = p2, p1
+			}
+			areas[i] += (p1[0]*p2[1] - p2[0]*p1[1]) / 2
+		}
+	}
+	for i, x := range areas {
+		areas[i] = math.Abs(x)
+	}
+	return areas
+}
+
+func (m *Model) layerHeight() float64 {
+	if m.LayerHeight > 0 {
+		return m.LayerHeight
+	}
+	return DefaultLayerHeight
+}
+
+// plateArea is the area of the floor of the build volume.
+func (m *Model) plateArea() float64 {
+	size := m.buildBox().Size()
+	return size.X * size.Y
+}
+
+// LayerAreas slices the meshHeights of the packed items into layers
+// LayerHeight thick and returns the fused area of each.
+func (m *Model) LayerAreas() []float64 {
+	height := m.layerHeight()
+	bottom, top := m.meshHeights()
+	// rounding in the rotations must not add an empty layer
+	n := int(math.Ceil((top-bottom)/height - 1e-9))
+	if n <= 0 {
+		return nil
+	}
+	areas := make([]float64, n)
+	for _, item := range m.Items {
+		p := item.Profiles[item.Rotation]
+		z0 := item.Translation.Z + p.Min
+		z1 := item.Translation.Z + p.Max
+		first := int(math.Floor((z0 - bottom) / height))
+		last := int(math.Ceil((z1 - bottom) / height))
+		if first < 0 {
+			first = 0
+		}
+		if last > n {
+			last = n
+		}
+		for i := first; i < last; i++ {
+			z := bottom + (float64(i)+0.5)*height
+			areas[i] += p.At(z - item.Translation.Z)
+		}
+	}
+	return areas
+}
+
+// ThermalExcess returns how far the fused area of the layers goes over
+// ThermalLimit as a fraction of the build plate, averaged over all layers.
+// It is 0 if no layer is over the limit.
+func (m *Model) ThermalExcess() float64 {
+	areas := m.LayerAreas()
+	plate := m.plateArea()
+	if len(areas) == 0 || plate <= 0 {
+		return 0
+	}
+	var excess float64
+	for _, area := range areas {
+		excess += math.Max(0, area/plate-m.ThermalLimit)
+	}
+	return excess / float64(len(areas))
+}
+
+// SaveLayers writes a CSV report of the fused area of every layer, its
+// fraction of the build plate and whether it is over ThermalLimit.
+func (m *Model) SaveLayers(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f := func(x float64) string {
+		return strconv.FormatFloat(x, 'f', 3, 64)
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"layer", "z_min", "z_max", "area", "fraction", "over_limit"})
+	height := m.layerHeight()
+	bottom, _ := m.meshHeights()
+	plate := m.plateArea()
+	for i, area := range m.LayerAreas() {
+		z := bottom + float64(i)*height
+		fraction := 0.0
+		if plate > 0 {
+			fraction = area / plate
+		}
+		over := m.ThermalLimit > 0 && fraction > m.ThermalLimit
+		w.Write([]string{strconv.Itoa(i), f(z), f(z + height), f(area), f(fraction), strconv.FormatBool(over)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
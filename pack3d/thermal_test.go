@@ -0,0 +1,78 @@
+package pack3d
+
+import (
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// hollowCube returns a cube of side 20 with a cube of side 16 cut out of
+// its middle.
+func hollowCube() *fauxgl.Mesh {
+	mesh := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 20}})
+	inner := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{2, 2, 2}, fauxgl.Vector{18, 18, 18}})
+	for _, t := range inner.Triangles {
+		t.V1, t.V2 = t.V2, t.V1
+	}
+	mesh.Add(inner)
+	return mesh
+}
+
+func TestSliceAreas(t *testing.T) {
+	box := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{2, 3, 4}})
+	tests := []struct {
+		name   string
+		mesh   *fauxgl.Mesh
+		axis   int
+		lo, hi float64
+		areas  []float64
+	}{
+		{"box along x", box, 0, 0, 2, []float64{12, 12}},
+		{"box along y", box, 1, 0, 3, []float64{8, 8, 8}},
+		{"box along z", box, 2, 0, 4, []float64{6, 6, 6, 6}},
+		{"box and the space above", box, 2, 0, 8, []float64{6, 6, 6, 6, 0, 0, 0, 0}},
+		{"hollow cube", hollowCube(), 2, 0, 20, []float64{400, 144, 144, 144, 144, 144, 144, 144, 144, 400}},
+	}
+	for _, test := range tests {
+		areas := sliceAreas(test.mesh, test.axis, test.lo, test.hi, len(test.areas))
+		for i := range areas {
+			if !closeTo(areas[i], test.areas[i]) {
+				t.Errorf("%s: slice %d is %g, want %g", test.name, i, areas[i], test.areas[i])
+			}
+		}
+	}
+}
+
+func TestLayerAreas(t *testing.T) {
+	container := fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 40}}
+	tests := []struct {
+		name   string
+		cubes  []testCube
+		areas  []float64
+		limit  float64
+		excess float64
+	}{
+		{"one cube", []testCube{{fauxgl.Vector{2.5, 2.5, 2.5}, 10, 0}}, []float64{100, 100, 100, 100, 100}, 0.2, 0.05},
+		{"under the limit", []testCube{{fauxgl.Vector{2.5, 2.5, 2.5}, 10, 0}}, []float64{100, 100, 100, 100, 100}, 0.3, 0},
+		{"cube on a cube", []testCube{{fauxgl.Vector{2.5, 2.5, 2.5}, 4, 0}, {fauxgl.Vector{2.5, 2.5, 6.5}, 6, 0}}, []float64{16, 16, 36, 36, 36}, 0.05, 0.04 * 3 / 5},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Container = container
+		m.LayerHeight = 2
+		m.ThermalLimit = test.limit
+		cubeModel(t, m, test.cubes...)
+		areas := m.LayerAreas()
+		if len(areas) != len(test.areas) {
+			t.Fatalf("%s: %d layers, want %d", test.name, len(areas), len(test.areas))
+		}
+		for i := range areas {
+			if !closeTo(areas[i], test.areas[i]) {
+				t.Errorf("%s: layer %d is %g, want %g", test.name, i, areas[i], test.areas[i])
+			}
+		}
+		if excess := m.ThermalExcess(); !closeTo(excess, test.excess) {
+			t.Errorf("%s: excess %g, want %g", test.name, excess, test.excess)
+		}
+	}
+}
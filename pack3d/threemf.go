@@ -0,0 +1,319 @@
+package pack3d
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/fauxgl"
+)
+
+const threeMFContentTypes = `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+ <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+ <Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>
+</Types>
+`
+
+const threeMFRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+ <Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>
+`
+
+// Save3MF writes a 3MF package with one object per mesh. If transforms is
+// nil every object is built once in place, otherwise object i is built once
+// per matrix in transforms[i], so repeated meshes are only stored once.
+// names may be nil.
+func Save3MF(path string, meshes []*fauxgl.Mesh, names []string, transforms [][]fauxgl.Matrix) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	z := zip.NewWriter(file)
+	if err := writeZipFile(z, "[Content_Types].xml", threeMFContentTypes); err != nil {
+		return err
+	}
+	if err := writeZipFile(z, "_rels/.rels", threeMFRels); err != nil {
+		return err
+	}
+	w, err := z.Create("3D/3dmodel.model")
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	write3MFModel(b, meshes, names, transforms)
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeZipFile(z *zip.Writer, name, data string) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, data)
+	return err
+}
+
+func write3MFModel(w *bufio.Writer, meshes []*fauxgl.Mesh, names []string, transforms [][]fauxgl.Matrix) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<model unit="millimeter" xml:lang="en-US" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02">`)
+	fmt.Fprintln(w, ` <resources>`)
+	for i, mesh := range meshes {
+		fmt.Fprintf(w, `  <object id="%d" type="model"`, i+1)
+		if i < len(names) {
+			fmt.Fprint(w, ` name="`)
+			xml.EscapeText(w, []byte(names[i]))
+			fmt.Fprint(w, `"`)
+		}
+		fmt.Fprintln(w, `>`)
+		fmt.Fprintln(w, `   <mesh>`)
+		fmt.Fprintln(w, `    <vertices>`)
+		lookup := make(map[fauxgl.Vector]int)
+		indexes := make([][3]int, len(mesh.Triangles))
+		for j, t := range mesh.Triangles {
+			for k, v := range [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position} {
+				index, ok := lookup[v]
+				if !ok {
+					index = len(lookup)
+					lookup[v] = index
+					fmt.Fprintf(w, `     <vertex x="%g" y="%g" z="%g"/>`+"\n", v.X, v.Y, v.Z)
+				}
+				indexes[j][k] = index
+			}
+		}
+		fmt.Fprintln(w, `    </vertices>`)
+		fmt.Fprintln(w, `    <triangles>`)
+		for _, t := range indexes {
+			if t[0] == t[1] || t[1] == t[2] || t[2] == t[0] {
+				continue
+			}
+			fmt.Fprintf(w, `     <triangle v1="%d" v2="%d" v3="%d"/>`+"\n", t[0], t[1], t[2])
+		}
+		fmt.Fprintln(w, `    </triangles>`)
+		fmt.Fprintln(w, `   </mesh>`)
+		fmt.Fprintln(w, `  </object>`)
+	}
+	fmt.Fprintln(w, ` </resources>`)
+	fmt.Fprintln(w, ` <build>`)
+	for i := range meshes {
+		if transforms == nil {
+			fmt.Fprintf(w, `  <item objectid="%d"/>`+"\n", i+1)
+			continue
+		}
+		for _, m := range transforms[i] {
+			// 3MF matrices transform row vectors
+			fmt.Fprintf(w, `  <item objectid="%d" transform="%g %g %g %g %g %g %g %g %g %g %g %g"/>`+"\n",
+				i+1, m.X00, m.X10, m.X20, m.X01, m.X11, m.X21, m.X02, m.X12, m.X22, m.X03, m.X13, m.X23)
+		}
+	}
+	fmt.Fprintln(w, ` </build>`)
+	fmt.Fprintln(w, `</model>`)
+}
+
+// Save3MF writes the model as a 3MF package that stores each distinct mesh
+// once, with one build item per model item.
+func (m *Model) Save3MF(path string) error {
+	var meshes []*fauxgl.Mesh
+	var names []string
+	var transforms [][]fauxgl.Matrix
+	lookup := make(map[*fauxgl.Mesh]int)
+	for _, item := range m.Items {
+		i, ok := lookup[item.Mesh]
+		if !ok {
+			i = len(meshes)
+			lookup[item.Mesh] = i
+			meshes = append(meshes, item.Mesh)
+			names = append(names, item.Name)
+			transforms = append(transforms, nil)
+		}
+		transforms[i] = append(transforms[i], item.Matrix())
+	}
+	return Save3MF(path, meshes, names, transforms)
+}
+
+var threeMFUnits = map[string]float64{
+	"":           1,
+	"micron":     0.001,
+	"millimeter": 1,
+	"centimeter": 10,
+	"inch":       25.4,
+	"foot":       304.8,
+	"meter":      1000,
+}
+
+type threeMFRelationships struct {
+	Relationships []struct {
+		Target string `xml:"Target,attr"`
+		Type   string `xml:"Type,attr"`
+	} `xml:"Relationship"`
+}
+
+type threeMFModel struct {
+	Unit    string          `xml:"unit,attr"`
+	Objects []threeMFObject `xml:"resources>object"`
+	Items   []threeMFItem   `xml:"build>item"`
+}
+
+type threeMFObject struct {
+	ID       int    `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Vertices []struct {
+		X float64 `xml:"x,attr"`
+		Y float64 `xml:"y,attr"`
+		Z float64 `xml:"z,attr"`
+	} `xml:"mesh>vertices>vertex"`
+	Triangles []struct {
+		V1 int `xml:"v1,attr"`
+		V2 int `xml:"v2,attr"`
+		V3 int `xml:"v3,attr"`
+	} `xml:"mesh>triangles>triangle"`
+	Components []threeMFItem `xml:"components>component"`
+}
+
+type threeMFItem struct {
+	ObjectID  int    `xml:"objectid,attr"`
+	Transform string `xml:"transform,attr"`
+}
+
+// Load3MF reads the build items of a 3MF package as separate meshes, placed
+// by their transforms and scaled to millimeters, along with their object
+// names.
+func Load3MF(path string) ([]*fauxgl.Mesh, []string, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer z.Close()
+	files := make(map[string]*zip.File)
+	for _, f := range z.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	name := "3D/3dmodel.model"
+	if f, ok := files["_rels/.rels"]; ok {
+		var rels threeMFRelationships
+		if err := decodeZipXML(f, &rels); err != nil {
+			return nil, nil, err
+		}
+		for _, rel := range rels.Relationships {
+			if strings.HasSuffix(rel.Type, "/3dmodel") {
+				name = strings.TrimPrefix(rel.Target, "/")
+			}
+		}
+	}
+	f, ok := files[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: missing %s", path, name)
+	}
+	var model threeMFModel
+	if err := decodeZipXML(f, &model); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	scale, ok := threeMFUnits[model.Unit]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: unknown unit %q", path, model.Unit)
+	}
+	objects := make(map[int]*threeMFObject)
+	for i := range model.Objects {
+		objects[model.Objects[i].ID] = &model.Objects[i]
+	}
+	unit := fauxgl.Scale(fauxgl.Vector{scale, scale, scale})
+	var meshes []*fauxgl.Mesh
+	var names []string
+	for _, item := range model.Items {
+		m, err := parse3MFTransform(item.Transform)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		mesh := fauxgl.NewEmptyMesh()
+		if err := add3MFObject(mesh, objects, item.ObjectID, m, 0); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		mesh.Transform(unit)
+		meshes = append(meshes, mesh)
+		names = append(names, objects[item.ObjectID].Name)
+	}
+	return meshes, names, nil
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// parse3MFTransform parses a 3MF transform attribute, the inverse of the
+// formatting in write3MFModel.
+func parse3MFTransform(s string) (fauxgl.Matrix, error) {
+	if s == "" {
+		return fauxgl.Identity(), nil
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 12 {
+		return fauxgl.Matrix{}, fmt.Errorf("invalid transform %q", s)
+	}
+	var v [12]float64
+	for i, field := range fields {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return fauxgl.Matrix{}, fmt.Errorf("invalid transform %q", s)
+		}
+		v[i] = x
+	}
+	return fauxgl.Matrix{
+		v[0], v[3], v[6], v[9],
+		v[1], v[4], v[7], v[10],
+		v[2], v[5], v[8], v[11],
+		0, 0, 0, 1,
+	}, nil
+}
+
+// add3MFObject adds the triangles of an object and its components to mesh,
+// transformed by m.
+func add3MFObject(mesh *fauxgl.Mesh, objects map[int]*threeMFObject, id int, m fauxgl.Matrix, depth int) error {
+	object, ok := objects[id]
+	if !ok {
+		return fmt.Errorf("missing object %d", id)
+	}
+	if depth > 16 {
+		return fmt.Errorf("object %d: components nested too deeply", id)
+	}
+	n := len(object.Vertices)
+	for _, t := range object.Triangles {
+		if t.V1 < 0 || t.V1 >= n || t.V2 < 0 || t.V2 >= n || t.V3 < 0 || t.V3 >= n {
+			return fmt.Errorf("object %d: vertex index out of range", id)
+		}
+		v1 := object.Vertices[t.V1]
+		v2 := object.Vertices[t.V2]
+		v3 := object.Vertices[t.V3]
+		mesh.Triangles = append(mesh.Triangles, fauxgl.NewTriangleForPoints(
+			m.MulPosition(fauxgl.Vector{v1.X, v1.Y, v1.Z}),
+			m.MulPosition(fauxgl.Vector{v2.X, v2.Y, v2.Z}),
+			m.MulPosition(fauxgl.Vector{v3.X, v3.Y, v3.Z})))
+	}
+	for _, c := range object.Components {
+		cm, err := parse3MFTransform(c.Transform)
+		if err != nil {
+			return err
+		}
+		if err := add3MFObject(mesh, objects, c.ObjectID, m.Mul(cm), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
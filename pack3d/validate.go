@@ -0,0 +1,163 @@
+package pack3d
+
+import (
+	"math"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// Collision is a pair of meshes closer than the required clearance.
+type Collision struct {
+	I, J     int
+	Distance float64    // smallest distance between the meshes, 0 if they intersect
+	Region   fauxgl.Box // spans the closest points of every offending triangle pair
+}
+
+// FindCollisions returns every pair of meshes closer than clearance, or
+// touching if clearance is zero. Bounding volume trees of the given depth
+// are used to skip pairs that are clearly far enough apart before triangle
+// distances are measured.
+func FindCollisions(meshes []*fauxgl.Mesh, clearance float64, depth int) []Collision {
+	padding := clearance / 2
+	trees := make([]Tree, len(meshes))
+	centers := make([]fauxgl.Vector, len(meshes))
+	boxes := make([]fauxgl.Box, len(meshes))
+	for i, mesh := range meshes {
+		trees[i] = NewTreeForMesh(mesh, depth, padding)
+		centers[i] = meshCenter(mesh)
+		boxes[i] = mesh.BoundingBox()
+	}
+	var result []Collision
+	for i := range meshes {
+		for j := i + 1; j < len(meshes); j++ {
+			// the trees only cover the surfaces, so a mesh inside the
+			// other may not touch them
+			nested := boxes[i].ContainsBox(boxes[j]) || boxes[j].ContainsBox(boxes[i])
+			if !nested && !trees[i].Intersects(trees[j], centers[i], centers[j]) {
+				continue
+			}
+			if c, ok := meshCollision(meshes[i], meshes[j], clearance); ok {
+				c.I = i
+				c.J = j
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+func meshCollision(a, b *fauxgl.Mesh, clearance float64) (Collision, bool) {
+	nearA := a.BoundingBox().Offset(clearance)
+	nearB := b.BoundingBox().Offset(clearance)
+	grid := newTriangleGrid(b.Triangles, nearA, clearance)
+	c := Collision{Distance: math.Inf(1), Region: fauxgl.EmptyBox}
+	found := false
+	for _, t1 := range a.Triangles {
+		box := t1.BoundingBox()
+		if !box.Intersects(nearB) {
+			continue
+		}
+		grid.Query(box.Offset(clearance), func(t2 *fauxgl.Triangle) {
+			d, p, q := TriangleDistance(t1, t2)
+			if d >= clearance && d > 0 {
+				return
+			}
+			found = true
+			c.Distance = math.Min(c.Distance, d)
+			c.Region = c.Region.Extend(fauxgl.Box{p.Min(q), p.Max(q)})
+		})
+	}
+	if !found {
+		// a mesh entirely inside the other does not touch its surface
+		for _, x := range [][2]*fauxgl.Mesh{{a, b}, {b, a}} {
+			inner, outer := x[0], x[1]
+			if len(inner.Triangles) == 0 || !outer.BoundingBox().ContainsBox(inner.BoundingBox()) {
+				continue
+			}
+			if InsideMesh(outer, inner.Triangles[0].V1.Position) {
+				return Collision{Distance: 0, Region: inner.BoundingBox()}, true
+			}
+		}
+	}
+	return c, found
+}
+
+// triangleGrid buckets triangles into cubic cells so that the triangles
+// near a box can be found quickly.
+type triangleGrid struct {
+	Size      float64
+	Triangles []*fauxgl.Triangle
+	Cells     map[[3]int][]int
+	seen      []int
+	stamp     int
+}
+
+// newTriangleGrid indexes the triangles that intersect bounds. Cells are at
+// least minSize wide.
+func newTriangleGrid(triangles []*fauxgl.Triangle, bounds fauxgl.Box, minSize float64) *triangleGrid {
+	var selected []*fauxgl.Triangle
+	var total float64
+	for _, t := range triangles {
+		box := t.BoundingBox()
+		if box.Intersects(bounds) {
+			selected = append(selected, t)
+			total += box.Size().MaxComponent()
+		}
+	}
+	size := minSize
+	if len(selected) > 0 {
+		size = math.Max(size, total/float64(len(selected)))
+	}
+	if size <= 0 {
+		size = 1
+	}
+	grid := &triangleGrid{size, selected, make(map[[3]int][]int), make([]int, len(selected)), 0}
+	for i, t := range selected {
+		lo, hi := grid.cells(t.BoundingBox())
+		for x := lo[0]; x <= hi[0]; x++ {
+			for y := lo[1]; y <= hi[1]; y++ {
+				for z := lo[2]; z <= hi[2]; z++ {
+					key := [3]int{x, y, z}
+					grid.Cells[key] = append(grid.Cells[key], i)
+				}
+			}
+		}
+	}
+	return grid
+}
+
+func (g *triangleGrid) cells(box fauxgl.Box) (lo, hi [3]int) {
+	lo = [3]int{
+		int(math.Floor(box.Min.X / g.Size)),
+		int(math.Floor(box.Min.Y / g.Size)),
+		int(math.Floor(box.Min.Z / g.Size)),
+	}
+	hi = [3]int{
+		int(math.Floor(box.Max.X / g.Size)),
+		int(math.Floor(box.Max.Y / g.Size)),
+		int(math.Floor(box.Max.Z / g.Size)),
+	}
+	return
+}
+
+// Query calls f once for every triangle whose bounding box intersects box.
+func (g *triangleGrid) Query(box fauxgl.Box, f func(*fauxgl.Triangle)) {
+	g.stamp++
+	lo, hi := g.cells(box)
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			for z := lo[2]; z <= hi[2]; z++ {
+				for _, i := range g.Cells[[3]int{x, y, z}] {
+					if g.seen[i] == g.stamp {
+						continue
+					}
+					g.seen[i] = g.stamp
+					t := g.Triangles[i]
+					if t.BoundingBox().Intersects(box) {
+						f(t)
+					}
+				}
+			}
+		}
+	}
+}
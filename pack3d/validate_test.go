@@ -0,0 +1,122 @@
+package pack3d
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// sampledDistance returns the smallest distance between points sampled
+// evenly over the two triangles, which is never less than the true one.
+func sampledDistance(t1, t2 *fauxgl.Triangle) float64 {
+	const n = 30
+	points := func(t *fauxgl.Triangle) []fauxgl.Vector {
+		var result []fauxgl.Vector
+		for i := 0; i <= n; i++ {
+			for j := 0; i+j <= n; j++ {
+				u, v := float64(i)/n, float64(j)/n
+				p := t.V1.Position.MulScalar(1 - u - v).Add(t.V2.Position.MulScalar(u)).Add(t.V3.Position.MulScalar(v))
+				result = append(result, p)
+			}
+		}
+		return result
+	}
+	best := math.Inf(1)
+	for _, p := range points(t1) {
+		for _, q := range points(t2) {
+			best = math.Min(best, p.Distance(q))
+		}
+	}
+	return best
+}
+
+func TestTriangleDistance(t *testing.T) {
+	v := fauxgl.V
+	floor := fauxgl.NewTriangleForPoints(v(0, 0, 0), v(4, 0, 0), v(0, 4, 0))
+	tests := []struct {
+		name     string
+		t        *fauxgl.Triangle
+		distance float64
+	}{
+		{"crossing", fauxgl.NewTriangleForPoints(v(1, 1, -1), v(1, 1, 1), v(2, 1, 1)), 0},
+		{"touching at a vertex", fauxgl.NewTriangleForPoints(v(1, 1, 0), v(1, 1, 2), v(2, 1, 2)), 0},
+		{"vertex above the face", fauxgl.NewTriangleForPoints(v(1, 1, 3), v(1, 1, 5), v(2, 1, 5)), 3},
+		{"parallel above", fauxgl.NewTriangleForPoints(v(0, 0, 2), v(4, 0, 2), v(0, 4, 2)), 2},
+		{"edge to edge", fauxgl.NewTriangleForPoints(v(3, 3, -1), v(3, 3, 1), v(5, 5, 0)), math.Sqrt(2)},
+		{"beside a vertex", fauxgl.NewTriangleForPoints(v(-3, 0, 0), v(-3, -1, 0), v(-4, 0, 0)), 3},
+	}
+	for _, test := range tests {
+		d, p, q := TriangleDistance(floor, test.t)
+		if !closeTo(d, test.distance) {
+			t.Errorf("%s: distance %g, want %g", test.name, d, test.distance)
+		}
+		if !closeTo(p.Distance(q), d) {
+			t.Errorf("%s: closest points %v and %v are %g apart, not %g", test.name, p, q, p.Distance(q), d)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	point := func() fauxgl.Vector {
+		return fauxgl.Vector{rnd.Float64() * 2, rnd.Float64() * 2, rnd.Float64() * 2}
+	}
+	for i := 0; i < 100; i++ {
+		t1 := fauxgl.NewTriangleForPoints(point(), point(), point())
+		t2 := fauxgl.NewTriangleForPoints(point(), point(), point())
+		d, _, _ := TriangleDistance(t1, t2)
+		// sampling finds points a little farther apart than the closest
+		if sampled := sampledDistance(t1, t2); d > sampled+1e-9 || sampled-d > 0.1 {
+			t.Errorf("triangles %d: distance %g, sampled %g", i, d, sampled)
+		}
+	}
+}
+
+func TestFindCollisions(t *testing.T) {
+	cube := func(min, max fauxgl.Vector) *fauxgl.Mesh {
+		return fauxgl.NewCubeForBox(fauxgl.Box{min, max})
+	}
+	v := fauxgl.V
+	meshes := []*fauxgl.Mesh{
+		cube(v(0, 0, 0), v(10, 10, 10)),
+		cube(v(12, 0, 0), v(20, 10, 10)),   // 2 from the first
+		cube(v(5, 5, 11), v(15, 15, 15)),   // 1 above both
+		cube(v(0, 0, 30), v(10, 10, 40)),   // far from everything
+		cube(v(30, 30, 30), v(31, 31, 31)), // inside the hollow cube
+		hollowCube(),
+	}
+	for _, t := range meshes[5].Triangles {
+		t.V1.Position = t.V1.Position.AddScalar(25)
+		t.V2.Position = t.V2.Position.AddScalar(25)
+		t.V3.Position = t.V3.Position.AddScalar(25)
+	}
+	tests := []struct {
+		clearance float64
+		pairs     [][2]int
+	}{
+		{0, nil},
+		{1.5, [][2]int{{0, 2}, {1, 2}}},
+		{2.5, [][2]int{{0, 1}, {0, 2}, {1, 2}}},
+	}
+	for _, test := range tests {
+		collisions := FindCollisions(meshes, test.clearance, 4)
+		if len(collisions) != len(test.pairs) {
+			t.Errorf("clearance %g: %d collisions, want %d", test.clearance, len(collisions), len(test.pairs))
+			continue
+		}
+		for i, c := range collisions {
+			if c.I != test.pairs[i][0] || c.J != test.pairs[i][1] {
+				t.Errorf("clearance %g: collision of %d and %d, want %v", test.clearance, c.I, c.J, test.pairs[i])
+			}
+			if c.Distance >= test.clearance {
+				t.Errorf("clearance %g: collision at %g", test.clearance, c.Distance)
+			}
+		}
+	}
+
+	// an item inside another is a collision even though they do not touch
+	collisions := FindCollisions([]*fauxgl.Mesh{meshes[0], cube(v(2, 2, 2), v(3, 3, 3))}, 0, 4)
+	if len(collisions) != 1 || collisions[0].Distance != 0 {
+		t.Errorf("item inside another: %v", collisions)
+	}
+}
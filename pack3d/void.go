@@ -0,0 +1,177 @@
+package pack3d
+
+import (
+	"math"
+	"sort"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// Void is a region of empty space inside a mesh's bounding box that is
+// enclosed by the mesh on at least five of its six sides, like the inside of
+// a cup or a box, where smaller items may fit.
+type Void struct {
+	Box    fauxgl.Box // bounds of the region
+	Volume float64    // volume of the empty voxels of the region
+}
+
+// FindVoids voxelizes mesh with resolution cells along its longest side and
+// returns the enclosed regions of empty voxels, largest first. Regions of
+// fewer than minCells voxels are left out. The mesh should be closed.
+func FindVoids(mesh *fauxgl.Mesh, resolution, minCells int) []Void {
+	box := mesh.BoundingBox()
+	size := box.Size()
+	cell := size.MaxComponent() / float64(resolution)
+	if cell <= 0 || len(mesh.Triangles) == 0 {
+		return nil
+	}
+	nx := int(math.Ceil(size.X/cell)) + 1
+	ny := int(math.Ceil(size.Y/cell)) + 1
+	nz := int(math.Ceil(size.Z/cell)) + 1
+	index := func(x, y, z int) int {
+		return (z*ny+y)*nx + x
+	}
+	cellOf := func(v, min float64, n int) int {
+		i := int((v - min) / cell)
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+
+	// mark the voxels that the surface passes through by sampling each
+	// triangle at intervals of half a voxel, and bucket triangles by column
+	solid := make([]bool, nx*ny*nz)
+	columns := make([][]int, nx*ny)
+	for i, t := range mesh.Triangles {
+		a, b, c := t.V1.Position, t.V2.Position, t.V3.Position
+		longest := math.Max(a.Distance(b), math.Max(b.Distance(c), c.Distance(a)))
+		n := int(math.Ceil(longest/(cell/2))) + 1
+		for u := 0; u <= n; u++ {
+			for v := 0; u+v <= n; v++ {
+				p := a.MulScalar(float64(n-u-v) / float64(n))
+				p = p.Add(b.MulScalar(float64(u) / float64(n)))
+				p = p.Add(c.MulScalar(float64(v) / float64(n)))
+				x := cellOf(p.X, box.Min.X, nx)
+				y := cellOf(p.Y, box.Min.Y, ny)
+				z := cellOf(p.Z, box.Min.Z, nz)
+				solid[index(x, y, z)] = true
+			}
+		}
+		tb := t.BoundingBox()
+		for y := cellOf(tb.Min.Y, box.Min.Y, ny); y <= cellOf(tb.Max.Y, box.Min.Y, ny); y++ {
+			for x := cellOf(tb.Min.X, box.Min.X, nx); x <= cellOf(tb.Max.X, box.Min.X, nx); x++ {
+				columns[y*nx+x] = append(columns[y*nx+x], i)
+			}
+		}
+	}
+
+	// fill the inside of the mesh by casting a ray up each column and
+	// counting the crossings below every voxel
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			// off center, so that rays do not pass exactly along the
+			// diagonal edges of axis aligned faces and count them twice
+			px := box.Min.X + (float64(x)+0.5137)*cell
+			py := box.Min.Y + (float64(y)+0.4791)*cell
+			p := fauxgl.Vector{px, py, box.Min.Z - cell}
+			q := fauxgl.Vector{px, py, box.Max.Z + cell}
+			var hits []float64
+			for _, i := range columns[y*nx+x] {
+				t := mesh.Triangles[i]
+				if h, ok := segmentTriangle(p, q, [3]fauxgl.Vector{t.V1.Position, t.V2.Position, t.V3.Position}); ok {
+					hits = append(hits, h.Z)
+				}
+			}
+			sort.Float64s(hits)
+			for z, j := 0, 0; z < nz; z++ {
+				pz := box.Min.Z + (float64(z)+0.5)*cell
+				for j < len(hits) && hits[j] < pz {
+					j++
+				}
+				if j%2 == 1 {
+					solid[index(x, y, z)] = true
+				}
+			}
+		}
+	}
+
+	// count the directions in which each empty voxel is blocked by solid
+	// voxels, scanning every row of the grid forwards and backwards
+	blocked := make([]uint8, nx*ny*nz)
+	scan := func(n int, at func(i int) int) {
+		seen := false
+		for i := 0; i < n; i++ {
+			j := at(i)
+			if solid[j] {
+				seen = true
+			} else if seen {
+				blocked[j]++
+			}
+		}
+		seen = false
+		for i := n - 1; i >= 0; i-- {
+			j := at(i)
+			if solid[j] {
+				seen = true
+			} else if seen {
+				blocked[j]++
+			}
+		}
+	}
+	for z := 0; z < nz; z++ {
+		for y := 0; y < ny; y++ {
+			scan(nx, func(i int) int { return index(i, y, z) })
+		}
+		for x := 0; x < nx; x++ {
+			scan(ny, func(i int) int { return index(x, i, z) })
+		}
+	}
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			scan(nz, func(i int) int { return index(x, y, i) })
+		}
+	}
+
+	// group the enclosed voxels into regions
+	var result []Void
+	visited := make([]bool, nx*ny*nz)
+	for start := range blocked {
+		if visited[start] || solid[start] || blocked[start] < 5 {
+			continue
+		}
+		region := fauxgl.EmptyBox
+		count := 0
+		stack := []int{start}
+		visited[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y, z := i%nx, (i/nx)%ny, i/(nx*ny)
+			min := box.Min.Add(fauxgl.Vector{float64(x), float64(y), float64(z)}.MulScalar(cell))
+			region = region.Extend(fauxgl.Box{min, min.AddScalar(cell)})
+			count++
+			neighbors := [6][3]int{{x - 1, y, z}, {x + 1, y, z}, {x, y - 1, z}, {x, y + 1, z}, {x, y, z - 1}, {x, y, z + 1}}
+			for _, n := range neighbors {
+				if n[0] < 0 || n[1] < 0 || n[2] < 0 || n[0] >= nx || n[1] >= ny || n[2] >= nz {
+					continue
+				}
+				j := index(n[0], n[1], n[2])
+				if !visited[j] && !solid[j] && blocked[j] >= 5 {
+					visited[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		if count >= minCells {
+			result = append(result, Void{region, float64(count) * cell * cell * cell})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Volume > result[j].Volume
+	})
+	return result
+}
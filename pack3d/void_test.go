@@ -0,0 +1,93 @@
+package pack3d
+
+import (
+	"testing"
+
+	"github.com/fogleman/fauxgl"
+)
+
+// cup returns a cube of side 20 hollowed out from the top, with walls and a
+// floor 2 thick.
+func cup() *fauxgl.Mesh {
+	mesh := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 20}})
+	inner := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{2, 2, 2}, fauxgl.Vector{18, 18, 20}})
+	for _, t := range inner.Triangles {
+		t.V1, t.V2 = t.V2, t.V1
+	}
+	mesh.Add(inner)
+	return mesh
+}
+
+func TestFindVoids(t *testing.T) {
+	tests := []struct {
+		name     string
+		mesh     *fauxgl.Mesh
+		minCells int
+		voids    []fauxgl.Box
+	}{
+		{"solid", fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{20, 20, 20}}), 1, nil},
+		{"hollow", hollowCube(), voidMinCells, []fauxgl.Box{{fauxgl.Vector{2, 2, 2}, fauxgl.Vector{18, 18, 18}}}},
+		{"cup", cup(), voidMinCells, []fauxgl.Box{{fauxgl.Vector{2, 2, 2}, fauxgl.Vector{18, 18, 20}}}},
+		{"too small", hollowCube(), voidResolution * voidResolution * voidResolution, nil},
+	}
+	for _, test := range tests {
+		// the voids are found to within a cell of 0.625
+		voids := FindVoids(test.mesh, voidResolution, test.minCells)
+		if len(voids) != len(test.voids) {
+			t.Errorf("%s: %d voids, want %d", test.name, len(voids), len(test.voids))
+			continue
+		}
+		for i, void := range voids {
+			want := test.voids[i]
+			if void.Box.Min.Distance(want.Min) > 1 || void.Box.Max.Distance(want.Max) > 1 {
+				t.Errorf("%s: void %d is %v, want %v", test.name, i, void.Box, want)
+			}
+			if v := want.Volume(); void.Volume < v*0.8 || void.Volume > v*1.2 {
+				t.Errorf("%s: void %d holds %g, want about %g", test.name, i, void.Volume, v)
+			}
+		}
+	}
+}
+
+func TestNesting(t *testing.T) {
+	small := fauxgl.NewCubeForBox(fauxgl.Box{fauxgl.Vector{}, fauxgl.Vector{5, 5, 5}})
+	tests := []struct {
+		nesting Nesting
+		nested  bool
+	}{
+		{NestingAllowed, false},
+		{NestingPreferred, true},
+		{NestingForbidden, false},
+	}
+	for _, test := range tests {
+		m := NewModel()
+		m.Nesting = test.nesting
+		for _, part := range []Part{{"cup", cup(), 1, nil, 0}, {"small", small, 1, nil, 0}} {
+			if err := m.AddPart(part, 6); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.nesting == NestingAllowed {
+			if m.Items[0].Voids != nil {
+				t.Errorf("%v: voids were found", test.nesting)
+			}
+			continue
+		}
+		if len(m.Items[0].Voids) != len(Rotations) {
+			t.Fatalf("%v: voids in %d rotations, want %d", test.nesting, len(m.Items[0].Voids), len(Rotations))
+		}
+		if got := nested(m.Items[1], m.Items[0]); got != test.nested {
+			t.Errorf("%v: nested %v, want %v", test.nesting, got, test.nested)
+		}
+		if test.nesting != NestingForbidden {
+			continue
+		}
+		// no move may leave the small item in the cup
+		for i := 0; i < 1000; i++ {
+			m.DoMove()
+			if nested(m.Items[1], m.Items[0]) {
+				t.Fatalf("%v: nested after %d moves", test.nesting, i+1)
+			}
+		}
+	}
+}